@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"sentinel/internal/database"
 	"sentinel/internal/email"
+	"sentinel/internal/events"
+	"sentinel/internal/fetcher"
 	"sentinel/internal/server"
+	"strconv"
+	"syscall"
+	"time"
 
 	"sentinel/internal/worker"
 
@@ -30,7 +39,34 @@ func main() {
 	defer dbPool.Close()
 	fmt.Println("🚀 Sentinel Database Connection Established")
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	workerPool := worker.New(dbPool, 100)
+	if v, err := strconv.Atoi(os.Getenv("CRAWL_PER_HOST_LIMIT")); err == nil && v > 0 {
+		workerPool.PerHostLimit = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("CRAWL_PER_HOST_DELAY")); err == nil {
+		workerPool.PerHostDelay = v
+	}
+	if ua := os.Getenv("SENTINEL_USER_AGENT"); ua != "" {
+		workerPool.UserAgent = ua
+		workerPool.Robots.UserAgent = ua
+	}
+	if v, err := time.ParseDuration(os.Getenv("ROBOTS_CACHE_TTL")); err == nil {
+		workerPool.Robots.TTL = v
+	}
+	fetchOpts := fetcher.Options{HTTP3: os.Getenv("FETCH_HTTP3") == "true"}
+	if v, err := strconv.Atoi(os.Getenv("FETCH_MAX_IDLE_PER_HOST")); err == nil && v > 0 {
+		fetchOpts.MaxIdleConnsPerHost = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("FETCH_DNS_CACHE_TTL")); err == nil {
+		fetchOpts.DNSCacheTTL = v
+	}
+	workerPool.Fetcher = fetcher.New(fetchOpts)
+	if os.Getenv("EVENTS_BACKEND") == "postgres" {
+		workerPool.Events = events.NewPGBroker(ctx, dbPool)
+	}
 	workerPool.Run()
 
 	fmt.Println("⚡ Creating jobs in DB and sending to workers...")
@@ -38,13 +74,15 @@ func main() {
 	emailClient := email.NewClient(os.Getenv("EMAIL_APIKEY"))
 	srv := server.NewServer(workerPool, emailClient)
 
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: server.LogFormatter}), gin.Recovery())
 
 	auth := r.Group("/api/auth")
 	{
 		auth.POST("/register", srv.RegisterHandler)
 		auth.POST("/verify", srv.VerifyHandler)
 		auth.POST("/login", srv.LoginHandler)
+		auth.POST("/guest", srv.GuestHandler)
 		auth.GET("/google/login", srv.GoogleLoginHandler)
 		auth.GET("/google/callback", srv.GoogleCallbackHandler)
 	}
@@ -56,12 +94,25 @@ func main() {
 		protected.POST("/upload", srv.UploadHandler)
 		protected.POST("/set-password", srv.SetPasswordHandler)
 
-		// Job Management
-		protected.GET("/jobs/:filename/status", srv.JobStatusHandler)
-		protected.GET("/jobs/:filename/download", srv.JobDownloadHandler)
-		protected.GET("/jobs/:filename/metrics", srv.JobMetricsHandler)
+		// Batches
+		protected.GET("/batches", srv.ListBatchesHandler)
+		protected.POST("/batches", srv.CreateBatchHandler)
+		protected.GET("/batches/:id", srv.GetBatchHandler)
+		protected.GET("/batches/:id/status", srv.BatchStatusHandler)
+		protected.GET("/batches/:id/download", srv.BatchDownloadHandler)
+		protected.GET("/batches/:id/metrics", srv.BatchMetricsHandler)
+		protected.GET("/batches/:id/events", srv.BatchEventsHandler)
+		protected.POST("/batches/:id/retry", srv.RetryBatchHandler)
+		protected.DELETE("/batches/:id", srv.DeleteBatchHandler)
+
+		// Filename-based aliases kept for older clients
+		protected.GET("/jobs/:filename/status", srv.BatchStatusHandler)
+		protected.GET("/jobs/:filename/download", srv.BatchDownloadHandler)
+		protected.GET("/jobs/:filename/metrics", srv.BatchMetricsHandler)
+		protected.GET("/jobs/:filename/events", srv.BatchEventsHandler)
 		protected.GET("/jobs", srv.ListJobsHandler)
-		protected.DELETE("/jobs/:filename", srv.DeleteJobHandler)
+		protected.POST("/jobs/:filename/retry", srv.RetryBatchHandler)
+		protected.DELETE("/jobs/:filename", srv.DeleteBatchHandler)
 
 	}
 
@@ -70,9 +121,42 @@ func main() {
 		port = "8081"
 	}
 
-	fmt.Printf("Listening at %s:\n", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Server failed to start: ", err)
+	shutdownTimeout := 30 * time.Second
+	if v, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil {
+		shutdownTimeout = v
 	}
 
+	httpServer := &http.Server{Addr: ":" + port, Handler: r}
+
+	go func() {
+		fmt.Printf("Listening at %s:\n", port)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Server failed to start: ", err)
+		}
+	}()
+
+	go srv.RunGuestCleanup(ctx, 10*time.Minute)
+
+	<-ctx.Done()
+
+	fmt.Println("🛑 Shutting down, draining in-flight work...")
+	// HTTP and the worker pool each get their own deadline: a long upload
+	// still ingesting mustn't eat the time in-flight fetches have to finish.
+	// Background ingests share the HTTP one
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelHTTP()
+
+	srv.Drain()
+	if err := httpServer.Shutdown(httpCtx); err != nil {
+		log.Println("HTTP shutdown: ", err)
+	}
+	// Uploads still ingesting past the deadline are rolled back
+	srv.StopIngest(httpCtx)
+
+	poolCtx, cancelPool := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelPool()
+	if err := workerPool.Shutdown(poolCtx); err != nil {
+		log.Println("Worker shutdown deadline hit, unfinished jobs returned to queue: ", err)
+	}
+	fmt.Println("👋 Sentinel stopped")
 }
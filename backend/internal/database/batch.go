@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"sentinel/internal/models"
+)
+
+const batchColumns = `id, COALESCE(user_id, 0), COALESCE(guest_id, ''), name, COALESCE(source_file, ''), settings,
+    total_jobs, completed_jobs, failed_jobs, blocked_jobs, dead_jobs, created_at, expires_at,
+    ingest_status, ingest_report, COALESCE(ingest_error, '')`
+
+func scanBatch(row pgx.Row) (*models.Batch, error) {
+	var b models.Batch
+	err := row.Scan(&b.ID, &b.UserID, &b.GuestID, &b.Name, &b.SourceFile, &b.Settings,
+		&b.Total, &b.Completed, &b.Failed, &b.Blocked, &b.Dead, &b.CreatedAt, &b.ExpiresAt,
+		&b.IngestStatus, &b.IngestReport, &b.IngestError)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// CreateBatch stores a new batch whose source is still to be ingested.
+func CreateBatch(pool *pgxpool.Pool, b *models.Batch) error {
+	query := `INSERT INTO batches(user_id, guest_id, name, source_file, settings, expires_at, ingest_status)
+              VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), $5, $6, $7) RETURNING id, created_at`
+	var userID *int
+	if b.UserID != 0 {
+		userID = &b.UserID
+	}
+	b.IngestStatus = models.IngestRunning
+	err := pool.QueryRow(context.Background(), query, userID, b.GuestID, b.Name, b.SourceFile, b.Settings, b.ExpiresAt, b.IngestStatus).Scan(&b.ID, &b.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("unable to insert batch: %w", err)
+	}
+	return nil
+}
+
+func GetBatch(pool *pgxpool.Pool, id int) (*models.Batch, error) {
+	query := "SELECT " + batchColumns + " FROM batches WHERE id = $1"
+	return scanBatch(pool.QueryRow(context.Background(), query, id))
+}
+
+// GetBatchSettings loads just the settings a worker needs to process a job.
+func GetBatchSettings(ctx context.Context, pool *pgxpool.Pool, id int) (*models.BatchSettings, error) {
+	var settings models.BatchSettings
+	err := pool.QueryRow(ctx, "SELECT settings FROM batches WHERE id = $1", id).Scan(&settings)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// GetBatchBySourceFile resolves the legacy filename-based routes to a batch.
+func GetBatchBySourceFile(pool *pgxpool.Pool, sourceFile string) (*models.Batch, error) {
+	query := "SELECT " + batchColumns + " FROM batches WHERE source_file = $1"
+	return scanBatch(pool.QueryRow(context.Background(), query, sourceFile))
+}
+
+func ListUserBatches(pool *pgxpool.Pool, userID int) ([]models.Batch, error) {
+	query := "SELECT " + batchColumns + " FROM batches WHERE user_id = $1 ORDER BY id DESC"
+	rows, err := pool.Query(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := []models.Batch{}
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, *b)
+	}
+	return batches, rows.Err()
+}
+
+func CountUserBatches(pool *pgxpool.Pool, userID int) (int, error) {
+	query := "SELECT COUNT(*) FROM batches WHERE user_id = $1"
+	var count int
+	err := pool.QueryRow(context.Background(), query, userID).Scan(&count)
+	return count, err
+}
+
+func CountGuestBatches(pool *pgxpool.Pool, guestID string) (int, error) {
+	query := "SELECT COUNT(*) FROM batches WHERE guest_id = $1"
+	var count int
+	err := pool.QueryRow(context.Background(), query, guestID).Scan(&count)
+	return count, err
+}
+
+// PurgeExpiredBatches deletes batches past their expiry, cascading to their
+// jobs and results, and returns the uploaded files left to remove.
+func PurgeExpiredBatches(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
+	query := "DELETE FROM batches WHERE expires_at < NOW() RETURNING COALESCE(source_file, '')"
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var f string
+		if err := rows.Scan(&f); err != nil {
+			return nil, err
+		}
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, rows.Err()
+}
+
+// FinishIngest marks a batch's source as fully turned into jobs and stores
+// the ingest report.
+func FinishIngest(ctx context.Context, pool *pgxpool.Pool, id int, report any) error {
+	query := "UPDATE batches SET ingest_status = $2, ingest_report = $3 WHERE id = $1"
+	_, err := pool.Exec(ctx, query, id, models.IngestReady, report)
+	return err
+}
+
+// FailIngest backs out a batch whose ingest failed part way: the jobs it
+// queued are deleted so it never runs half its URLs, and the batch is kept
+// with the error for its owner to see.
+func FailIngest(ctx context.Context, pool *pgxpool.Pool, id int, report any, ingestErr string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM jobs WHERE batch_id = $1", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM crawl_frontier WHERE batch_id = $1", id); err != nil {
+		return err
+	}
+	query := `UPDATE batches SET ingest_status = $2, ingest_report = $3, ingest_error = $4,
+                  total_jobs = 0, completed_jobs = 0, failed_jobs = 0, blocked_jobs = 0, dead_jobs = 0
+              WHERE id = $1`
+	if _, err := tx.Exec(ctx, query, id, models.IngestFailed, report, ingestErr); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteBatch removes a user's batch; its jobs and results cascade with it.
+func DeleteBatch(pool *pgxpool.Pool, id int, userID int) error {
+	query := "DELETE FROM batches WHERE id = $1 AND user_id = $2"
+	_, err := pool.Exec(context.Background(), query, id, userID)
+	return err
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"sentinel/internal/models"
+)
+
+// EnqueueCrawlJobs adds jobs to a crawl batch, skipping URLs the batch's
+// frontier has already seen and stopping once the batch holds maxPages jobs
+// (0 means no budget). Jobs keep their slice order, so when the budget runs
+// out the earliest ones win. The jobs that were created are returned; their
+// IDs aren't filled in.
+//
+// The batch row is locked for the duration, so workers finishing pages of the
+// same crawl at once can't both spend the last of the budget.
+func EnqueueCrawlJobs(ctx context.Context, pool *pgxpool.Pool, batchID int, jobs []models.Job, maxPages int) ([]models.Job, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var total int
+	if err := tx.QueryRow(ctx, "SELECT total_jobs FROM batches WHERE id = $1 FOR UPDATE", batchID).Scan(&total); err != nil {
+		return nil, fmt.Errorf("lock batch %d: %w", batchID, err)
+	}
+	limit := len(jobs)
+	if maxPages > 0 {
+		limit = min(limit, maxPages-total)
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	urls := make([]string, len(jobs))
+	for i := range jobs {
+		urls[i] = jobs[i].URL
+	}
+
+	// DISTINCT ON keeps the first position of URLs repeated within the call
+	query := `
+        INSERT INTO crawl_frontier(batch_id, url)
+        SELECT $1, url FROM (
+            SELECT DISTINCT ON (url) url, ord
+            FROM unnest($2::text[]) WITH ORDINALITY AS t(url, ord)
+            WHERE NOT EXISTS (SELECT 1 FROM crawl_frontier f WHERE f.batch_id = $1 AND f.url = t.url)
+            ORDER BY url, ord
+        ) fresh
+        ORDER BY ord
+        LIMIT $3
+        ON CONFLICT DO NOTHING
+        RETURNING url
+    `
+	rows, err := tx.Query(ctx, query, batchID, urls, limit)
+	if err != nil {
+		return nil, err
+	}
+	added := make(map[string]bool)
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		added[u] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fresh := make([]models.Job, 0, len(added))
+	for _, job := range jobs {
+		if added[job.URL] {
+			fresh = append(fresh, job)
+			delete(added, job.URL)
+		}
+	}
+	if _, err := insertJobs(ctx, tx, fresh); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
@@ -2,31 +2,106 @@ package database
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"sentinel/internal/models"
 )
 
-func CreateJob(dbPool *pgxpool.Pool, job *models.Job) error {
-	query := "INSERT INTO jobs(url,status,file_path,job_type,user_id) VALUES ($1,$2,$3,$4,$5) RETURNING id"
-	var userID *int
-	if job.UserID != 0 {
-		userID = &job.UserID
+// JobBatchSize is how many jobs CreateJobs callers should send per call.
+const JobBatchSize = 1000
+
+// CreateJobs inserts a chunk of jobs in a single round-trip and returns the
+// new IDs. They come back in no particular order, so they aren't matched up
+// with the jobs. Each call commits on its own, so workers can start on one
+// chunk while the caller is still writing the next.
+func CreateJobs(ctx context.Context, pool *pgxpool.Pool, jobs []models.Job) ([]int, error) {
+	return insertJobs(ctx, pool, jobs)
+}
+
+// queryer is satisfied by both *pgxpool.Pool and pgx.Tx.
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func insertJobs(ctx context.Context, q queryer, jobs []models.Job) ([]int, error) {
+	n := len(jobs)
+	if n == 0 {
+		return nil, nil
+	}
+	urls := make([]string, n)
+	statuses := make([]string, n)
+	filePaths := make([]string, n)
+	jobTypes := make([]string, n)
+	userIDs := make([]*int, n)
+	hosts := make([]string, n)
+	batchIDs := make([]int, n)
+	parentIDs := make([]*int, n)
+	depths := make([]int, n)
+	metadata := make([]*string, n)
+	roots := make([]*string, n)
+	for i := range jobs {
+		urls[i] = jobs[i].URL
+		statuses[i] = jobs[i].Status
+		filePaths[i] = jobs[i].FilePath
+		jobTypes[i] = jobs[i].JobType
+		if jobs[i].UserID != 0 {
+			userIDs[i] = &jobs[i].UserID
+		}
+		hosts[i] = jobHost(jobs[i].URL)
+		batchIDs[i] = jobs[i].BatchID
+		if jobs[i].ParentID != 0 {
+			parentIDs[i] = &jobs[i].ParentID
+		}
+		depths[i] = jobs[i].Depth
+		if jobs[i].CrawlRoot != "" && jobs[i].CrawlRoot != jobs[i].URL {
+			roots[i] = &jobs[i].CrawlRoot
+		}
+		if len(jobs[i].Metadata) > 0 {
+			raw, err := json.Marshal(jobs[i].Metadata)
+			if err != nil {
+				return nil, fmt.Errorf("job %q metadata: %w", jobs[i].URL, err)
+			}
+			meta := string(raw)
+			metadata[i] = &meta
+		}
 	}
-	err := dbPool.QueryRow(context.Background(), query, job.URL, job.Status, job.FilePath, job.JobType, userID).Scan(&job.ID)
-	if err != nil {
-		fmt.Printf("Failed to insert job: %v\n", err)
 
+	query := `
+        INSERT INTO jobs(url,status,file_path,job_type,user_id,host,batch_id,parent_id,depth,metadata,crawl_root)
+        SELECT u, s, f, t, uid, h, b, p, d, m::jsonb, r
+        FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::int[], $6::text[], $7::int[], $8::int[], $9::int[], $10::text[], $11::text[])
+            AS x(u, s, f, t, uid, h, b, p, d, m, r)
+        RETURNING id
+    `
+	rows, err := q.Query(ctx, query, urls, statuses, filePaths, jobTypes, userIDs, hosts, batchIDs, parentIDs, depths, metadata, roots)
+	if err != nil {
+		fmt.Printf("Failed to insert jobs: %v\n", err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	return err
+	ids := make([]int, 0, n)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
 }
 
-func UpdateJobStatus(pool *pgxpool.Pool, jobId int, status string) error {
-	query := "UPDATE jobs SET status = $1 WHERE id = $2"
-	_, err := pool.Exec(context.Background(), query, status, jobId)
+func UpdateJobStatus(ctx context.Context, pool *pgxpool.Pool, jobId int, status string) error {
+	query := "UPDATE jobs SET status = $1, locked_until = NULL WHERE id = $2"
+	_, err := pool.Exec(ctx, query, status, jobId)
 	if err != nil {
 		fmt.Println("Error updating jobs", err)
 
@@ -35,3 +110,63 @@ func UpdateJobStatus(pool *pgxpool.Pool, jobId int, status string) error {
 	return err
 
 }
+
+// StartJobAttempt marks a job as in progress, renews its lease and returns
+// its attempt number. It returns ErrLeaseLost if owner no longer holds the
+// job, which then belongs to whoever claimed it since.
+func StartJobAttempt(ctx context.Context, pool *pgxpool.Pool, owner string, jobId int, lease time.Duration) (int, error) {
+	query := `
+        UPDATE jobs SET status = 'InProgress', attempts = attempts + 1,
+            locked_until = NOW() + make_interval(secs => $2)
+        WHERE id = $1 AND status = 'Queued' AND lease_owner = $3
+        RETURNING attempts
+    `
+	var attempts int
+	err := pool.QueryRow(ctx, query, jobId, lease.Seconds(), owner).Scan(&attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrLeaseLost
+	}
+	if err != nil {
+		fmt.Println("Error starting job attempt", err)
+	}
+	return attempts, err
+}
+
+// RecordJobFailure stores the outcome of a failed attempt along with its error.
+// retryIn delays when the queue hands the job out again.
+func RecordJobFailure(ctx context.Context, pool *pgxpool.Pool, jobId int, status string, lastError string, retryIn time.Duration) error {
+	query := `
+        UPDATE jobs SET status = $1, last_error = $2, locked_until = NULL,
+            run_at = NOW() + make_interval(secs => $4)
+        WHERE id = $3
+    `
+	_, err := pool.Exec(ctx, query, status, lastError, jobId, retryIn.Seconds())
+	if err != nil {
+		fmt.Println("Error recording job failure", err)
+	}
+	return err
+}
+
+// RequeueDeadJobs puts a batch's dead jobs back to pending with a fresh
+// attempt budget. The queue picks them up on its next claim.
+func RequeueDeadJobs(pool *pgxpool.Pool, batchID int) (int64, error) {
+	query := `
+        UPDATE jobs SET status = 'pending', attempts = 0, last_error = NULL, run_at = NOW()
+        WHERE batch_id = $1 AND status = 'Dead'
+    `
+	tag, err := pool.Exec(context.Background(), query, batchID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// jobHost is the lower-cased host a job targets; the queue uses it to avoid
+// claiming more work for hosts the scheduler is already backed up on.
+func jobHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -3,46 +3,34 @@ package database
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"path/filepath"
 	"sentinel/internal/models"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func CountUserFiles(pool *pgxpool.Pool, userID int) (int, error) {
-	query := "SELECT COUNT(DISTINCT file_path) FROM jobs WHERE user_id = $1"
-	var count int
-	err := pool.QueryRow(context.Background(), query, userID).Scan(&count)
-	return count, err
+// ResultFilter narrows GetJobResults; the zero value returns everything.
+type ResultFilter struct {
+	// CertExpiresWithinDays keeps HTTPS results whose certificate expires
+	// within this many days of now, expired ones included
+	CertExpiresWithinDays *int
 }
 
-func GetJobProgress(pool *pgxpool.Pool, filePath string) (total int, completed int, failed int, err error) {
-	queryTotal := "SELECT COUNT(*) FROM jobs WHERE file_path = $1"
-	queryCompleted := "SELECT COUNT(*) FROM jobs WHERE file_path = $1 AND status = 'Completed'"
-	// We don't have 'Failed' status yet in worker, but we will add it.
-	queryFailed := "SELECT COUNT(*) FROM jobs WHERE file_path = $1 AND status = 'Failed'"
-
-	if err := pool.QueryRow(context.Background(), queryTotal, filePath).Scan(&total); err != nil {
-		return 0, 0, 0, err
-	}
-	if err := pool.QueryRow(context.Background(), queryCompleted, filePath).Scan(&completed); err != nil {
-		return 0, 0, 0, err
-	}
-	if err := pool.QueryRow(context.Background(), queryFailed, filePath).Scan(&failed); err != nil {
-		return 0, 0, 0, err
-	}
-	return total, completed, failed, nil
-}
-
-func GetJobResults(pool *pgxpool.Pool, filePath string) ([]models.CrawlData, error) {
+func GetJobResults(pool *pgxpool.Pool, batchID int, filter ResultFilter) ([]models.CrawlData, error) {
 	// Join jobs and results
 	query := `
-        SELECT r.data 
+        SELECT r.data, j.id, COALESCE(j.parent_id, 0), j.depth, j.metadata
         FROM results r 
         JOIN jobs j ON r.job_id = j.id 
-        WHERE j.file_path = $1
+        WHERE j.batch_id = $1
     `
-	rows, err := pool.Query(context.Background(), query, filePath)
+	args := []any{batchID}
+	if filter.CertExpiresWithinDays != nil {
+		args = append(args, *filter.CertExpiresWithinDays)
+		query += fmt.Sprintf(" AND (r.data->'tls'->'certificate'->>'not_after')::timestamptz < NOW() + make_interval(days => $%d)", len(args))
+	}
+	rows, err := pool.Query(context.Background(), query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -51,27 +39,24 @@ func GetJobResults(pool *pgxpool.Pool, filePath string) ([]models.CrawlData, err
 	var results []models.CrawlData
 	for rows.Next() {
 		var dataJSON []byte
-		if err := rows.Scan(&dataJSON); err != nil {
+		var jobID, parentID, depth int
+		var metadata map[string]any
+		if err := rows.Scan(&dataJSON, &jobID, &parentID, &depth, &metadata); err != nil {
 			continue
 		}
 		var data models.CrawlData
 		if err := json.Unmarshal(dataJSON, &data); err == nil {
+			data.JobID, data.ParentID, data.Depth = jobID, parentID, depth
+			data.Metadata = metadata
 			results = append(results, data)
 		}
 	}
 	return results, nil
 }
 
-func DeleteJobByFilePath(pool *pgxpool.Pool, filePath string, userID int) error {
-	// Delete jobs, cascading will delete results.
-	// Ensure it belongs to user
-	query := "DELETE FROM jobs WHERE file_path = $1 AND user_id = $2"
-	_, err := pool.Exec(context.Background(), query, filePath, userID)
-	return err
-}
-
+// GetUserJobs lists the uploaded filenames of a user's batches, newest first.
 func GetUserJobs(pool *pgxpool.Pool, userID int) ([]string, error) {
-	query := "SELECT DISTINCT file_path FROM jobs WHERE user_id = $1 ORDER BY file_path DESC"
+	query := "SELECT source_file FROM batches WHERE user_id = $1 AND source_file IS NOT NULL ORDER BY id DESC"
 	rows, err := pool.Query(context.Background(), query, userID)
 	if err != nil {
 		return nil, err
@@ -2,6 +2,11 @@ package database
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"sentinel/internal/models"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -9,32 +14,60 @@ import (
 // Metric struct for aggregated data
 type JobMetrics struct {
 	TotalRequests       int            `json:"total_requests"`
-	AverageResponseTime float64        `json:"avg_response_time"`
+	AverageResponseTime float64        `json:"avg_response_time"` // mean of timings.total_ms
+	AverageTimings      models.Timings `json:"avg_timings"`
 	StatusCodes         map[string]int `json:"status_codes"`
 	TotalDataSize       int            `json:"total_data_size"` // Estimated
+	JobStatuses         map[string]int `json:"job_statuses"`    // Completed, Failed, Blocked...
+	// Security aggregates the "security" audits; nil if none ran
+	Security *SecurityMetrics `json:"security,omitempty"`
+}
+
+// SecurityMetrics summarizes the security audits of a batch's pages.
+type SecurityMetrics struct {
+	Audited      int            `json:"audited"`
+	AverageScore float64        `json:"avg_score"`
+	Grades       map[string]int `json:"grades"`
+	// Failing counts the pages failing each check
+	Failing map[string]int `json:"failing"`
 }
 
-func GetJobMetrics(pool *pgxpool.Pool, filePath string) (JobMetrics, error) {
-	metrics := JobMetrics{StatusCodes: make(map[string]int)}
+// securityChecks are the audit's header checks, by JSON key.
+var securityChecks = []string{"hsts", "csp", "x_frame_options", "x_content_type_options", "referrer_policy", "permissions_policy"}
+
+func GetJobMetrics(pool *pgxpool.Pool, batchID int) (JobMetrics, error) {
+	metrics := JobMetrics{StatusCodes: make(map[string]int), JobStatuses: make(map[string]int)}
 
 	// We fetch all results and aggregate in Go to avoid complex SQL for now,
 	// or use smart SQL. Let's use SQL for efficiency where possible but we have JSONB.
-	// Casting JSONB to int in Postgres: (data->>'response_time')::int
+	// Casting JSONB to float in Postgres: (data->'timings'->>'total_ms')::float8
 
-	// Metrics: Total Requests, Avg Response Time, Total Data Size
+	// Metrics: Total Requests, Avg Timings, Total Data Size.
+	// Results stored before timings existed only carry response_time
 	queryStats := `
         SELECT 
             COUNT(*), 
-            COALESCE(AVG((r.data->>'response_time')::int), 0),
+            COALESCE(AVG(COALESCE((r.data->'timings'->>'total_ms')::float8, (r.data->>'response_time')::float8)), 0),
+            COALESCE(AVG((r.data->'timings'->>'dns_ms')::float8), 0),
+            COALESCE(AVG((r.data->'timings'->>'connect_ms')::float8), 0),
+            COALESCE(AVG((r.data->'timings'->>'tls_ms')::float8), 0),
+            COALESCE(AVG((r.data->'timings'->>'ttfb_ms')::float8), 0),
+            COALESCE(AVG((r.data->'timings'->>'download_ms')::float8), 0),
             COALESCE(SUM(OCTET_LENGTH(r.data::text)), 0)
         FROM results r 
         JOIN jobs j ON r.job_id = j.id 
-        WHERE j.file_path = $1
+        WHERE j.batch_id = $1
     `
-	err := pool.QueryRow(context.Background(), queryStats, filePath).Scan(&metrics.TotalRequests, &metrics.AverageResponseTime, &metrics.TotalDataSize)
+	avg := &metrics.AverageTimings
+	err := pool.QueryRow(context.Background(), queryStats, batchID).Scan(
+		&metrics.TotalRequests, &metrics.AverageResponseTime,
+		&avg.DNS, &avg.Connect, &avg.TLS, &avg.TTFB, &avg.Download,
+		&metrics.TotalDataSize,
+	)
 	if err != nil {
 		return metrics, err
 	}
+	avg.Total = metrics.AverageResponseTime
 
 	// Status Codes Distribution
 	queryCodes := `
@@ -43,10 +76,10 @@ func GetJobMetrics(pool *pgxpool.Pool, filePath string) (JobMetrics, error) {
             COUNT(*) 
         FROM results r 
         JOIN jobs j ON r.job_id = j.id 
-        WHERE j.file_path = $1 
+        WHERE j.batch_id = $1 
         GROUP BY 1
     `
-	rows, err := pool.Query(context.Background(), queryCodes, filePath)
+	rows, err := pool.Query(context.Background(), queryCodes, batchID)
 	if err != nil {
 		return metrics, err // Return partial metrics if code query fails
 	}
@@ -60,5 +93,81 @@ func GetJobMetrics(pool *pgxpool.Pool, filePath string) (JobMetrics, error) {
 		}
 	}
 
+	// Job outcomes, so robots.txt blocks aren't lumped in with failures
+	queryStatuses := "SELECT status, COUNT(*) FROM jobs WHERE batch_id = $1 GROUP BY 1"
+	statusRows, err := pool.Query(context.Background(), queryStatuses, batchID)
+	if err != nil {
+		return metrics, err
+	}
+	defer statusRows.Close()
+
+	for statusRows.Next() {
+		var status string
+		var count int
+		if err := statusRows.Scan(&status, &count); err == nil {
+			metrics.JobStatuses[status] = count
+		}
+	}
+
+	security, err := getSecurityMetrics(pool, batchID)
+	if err != nil {
+		return metrics, err
+	}
+	metrics.Security = security
+
 	return metrics, nil
 }
+
+func getSecurityMetrics(pool *pgxpool.Pool, batchID int) (*SecurityMetrics, error) {
+	audits := `
+        SELECT r.data->'extracted'->'security' AS s
+        FROM results r
+        JOIN jobs j ON r.job_id = j.id
+        WHERE j.batch_id = $1 AND r.data->'extracted' ? 'security'
+    `
+
+	// One FILTER column per check keeps this to a single pass
+	columns := []string{"COUNT(*)", "COALESCE(AVG((s->>'score')::float8), 0)"}
+	for _, check := range securityChecks {
+		columns = append(columns, fmt.Sprintf("COUNT(*) FILTER (WHERE NOT (s->'%s'->>'pass')::bool)", check))
+	}
+	columns = append(columns,
+		"COUNT(*) FILTER (WHERE (s->'csp'->>'unsafe_inline')::bool)",
+		"COUNT(*) FILTER (WHERE (s->'csp'->>'unsafe_eval')::bool)",
+		"COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM jsonb_array_elements(COALESCE(s->'cookies', '[]')) c WHERE NOT (c->>'pass')::bool))",
+		"COUNT(*) FILTER (WHERE NOT (s->>'https')::bool)",
+	)
+	keys := append(slices.Clone(securityChecks), "csp_unsafe_inline", "csp_unsafe_eval", "cookies", "https")
+
+	security := &SecurityMetrics{Grades: make(map[string]int), Failing: make(map[string]int, len(keys))}
+	counts := make([]int, len(keys))
+	dest := []any{&security.Audited, &security.AverageScore}
+	for i := range counts {
+		dest = append(dest, &counts[i])
+	}
+	query := fmt.Sprintf("SELECT %s FROM (%s) audits", strings.Join(columns, ", "), audits)
+	if err := pool.QueryRow(context.Background(), query, batchID).Scan(dest...); err != nil {
+		return nil, err
+	}
+	if security.Audited == 0 {
+		return nil, nil
+	}
+	for i, key := range keys {
+		security.Failing[key] = counts[i]
+	}
+
+	rows, err := pool.Query(context.Background(), "SELECT s->>'grade', COUNT(*) FROM ("+audits+") audits GROUP BY 1", batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var grade string
+		var count int
+		if err := rows.Scan(&grade, &count); err == nil {
+			security.Grades[grade] = count
+		}
+	}
+	return security, rows.Err()
+}
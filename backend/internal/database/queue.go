@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"sentinel/internal/models"
+)
+
+// ErrLeaseLost is returned for a job whose lease ran out and was claimed
+// by someone else before its holder got to it.
+var ErrLeaseLost = errors.New("job lease lost")
+
+// claimWindow is how many runnable rows per requested job a claim looks at
+// when capping hosts. Rows for other hosts further back wait for a later
+// claim.
+const claimWindow = 10
+
+// ClaimJobs leases up to limit runnable jobs to owner, at most perHost of
+// them for any one host so a single domain can't tie up leases it won't get
+// to before they run out. Rows locked by another claimer are skipped, and
+// so are jobs for hosts in skipHosts. Jobs whose lease expired (their
+// worker died) are handed out again.
+func ClaimJobs(ctx context.Context, pool *pgxpool.Pool, owner string, limit, perHost int, lease time.Duration, skipHosts []string) ([]models.Job, error) {
+	// Window functions can't be combined with FOR UPDATE, so hosts are
+	// capped first and the runnable check is repeated on the locked rows.
+	// Only the next few runnable rows of each index are ranked, so a claim
+	// doesn't sort the whole backlog.
+	query := `
+        WITH candidates AS (
+            (SELECT id, host, run_at FROM jobs
+             WHERE status IN ('pending', 'Retrying') AND run_at <= NOW()
+               AND NOT (COALESCE(host, '') = ANY($3))
+             ORDER BY run_at, id
+             LIMIT $6)
+            UNION ALL
+            (SELECT id, host, run_at FROM jobs
+             WHERE status IN ('Queued', 'InProgress') AND locked_until < NOW()
+               AND NOT (COALESCE(host, '') = ANY($3))
+             ORDER BY locked_until
+             LIMIT $6)
+        ), ranked AS (
+            SELECT id, ROW_NUMBER() OVER (PARTITION BY host ORDER BY run_at, id) AS n
+            FROM candidates
+        )
+        UPDATE jobs SET status = 'Queued', locked_until = NOW() + make_interval(secs => $2), lease_owner = $5
+        WHERE id IN (
+            SELECT id FROM jobs
+            WHERE id IN (SELECT id FROM ranked WHERE n <= $4)
+              AND ((status IN ('pending', 'Retrying') AND run_at <= NOW())
+                OR (status IN ('Queued', 'InProgress') AND locked_until < NOW()))
+            ORDER BY run_at, id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, url, COALESCE(user_id, 0), COALESCE(batch_id, 0), COALESCE(file_path, ''), job_type, status, attempts, depth, COALESCE(crawl_root, url)
+    `
+	if skipHosts == nil {
+		skipHosts = []string{}
+	}
+	rows, err := pool.Query(ctx, query, limit, lease.Seconds(), skipHosts, perHost, owner, limit*claimWindow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []models.Job
+	for rows.Next() {
+		var job models.Job
+		if err := rows.Scan(&job.ID, &job.URL, &job.UserID, &job.BatchID, &job.FilePath, &job.JobType, &job.Status, &job.Attempts, &job.Depth, &job.CrawlRoot); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// RenewLeases extends the lease of every job owner still holds, queued or
+// in progress, so jobs waiting on a slow host in memory aren't claimed
+// again underneath it.
+func RenewLeases(ctx context.Context, pool *pgxpool.Pool, owner string, lease time.Duration) (int64, error) {
+	query := `
+        UPDATE jobs SET locked_until = NOW() + make_interval(secs => $2)
+        WHERE lease_owner = $1 AND status IN ('Queued', 'InProgress')
+    `
+	tag, err := pool.Exec(ctx, query, owner, lease.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RecoverJobs returns jobs left queued or in progress by a previous process
+// to pending. Only rows whose lease has run out (or never had one) are
+// touched, so jobs held by other live replicas are left alone.
+func RecoverJobs(ctx context.Context, pool *pgxpool.Pool) (int64, error) {
+	query := `
+        UPDATE jobs SET status = 'pending', locked_until = NULL
+        WHERE status IN ('Queued', 'InProgress')
+          AND (locked_until IS NULL OR locked_until < NOW())
+    `
+	tag, err := pool.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ReleaseJobs hands jobs owner claimed back to the queue; jobs someone
+// else has claimed since are left alone. Set refund when the job's attempt
+// was interrupted and shouldn't count against its retries.
+func ReleaseJobs(ctx context.Context, pool *pgxpool.Pool, owner string, ids []int, refund bool) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	query := `
+        UPDATE jobs SET status = 'pending', locked_until = NULL, lease_owner = NULL,
+            attempts = CASE WHEN $2 THEN GREATEST(attempts - 1, 0) ELSE attempts END
+        WHERE id = ANY($1) AND status IN ('Queued', 'InProgress') AND lease_owner = $3
+    `
+	tag, err := pool.Exec(ctx, query, ids, refund, owner)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"sync"
+)
+
+// Event is published by workers as jobs change state and fanned out to
+// anyone watching the job's batch.
+type Event struct {
+	BatchID int    `json:"batch_id"`
+	JobID   int    `json:"job_id"`
+	URL     string `json:"url"`
+	Status  string `json:"status"`
+}
+
+// Broker fans events out to subscribers of a batch.
+type Broker interface {
+	Publish(ev Event)
+	// Subscribe returns a channel of events for a batch and a func that
+	// must be called to stop receiving them.
+	Subscribe(batchID int) (<-chan Event, func())
+}
+
+// subscriberBuffer bounds how far a slow client may fall behind before
+// events are dropped for it; progress counters are re-read from the DB, so a
+// dropped per-URL event never leaves a client with wrong totals.
+const subscriberBuffer = 256
+
+// MemoryBroker delivers events within a single process.
+type MemoryBroker struct {
+	mu   sync.RWMutex
+	subs map[int]map[chan Event]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subs: make(map[int]map[chan Event]struct{})}
+}
+
+func (b *MemoryBroker) Publish(ev Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subs[ev.BatchID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (b *MemoryBroker) Subscribe(batchID int) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[batchID] == nil {
+		b.subs[batchID] = make(map[chan Event]struct{})
+	}
+	b.subs[batchID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[batchID], ch)
+			if len(b.subs[batchID]) == 0 {
+				delete(b.subs, batchID)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
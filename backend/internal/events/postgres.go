@@ -0,0 +1,80 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const pgChannel = "sentinel_events"
+
+// PGBroker relays events through Postgres LISTEN/NOTIFY so every API replica
+// sees what any replica's workers publish. Delivery to local subscribers
+// goes through the notification round-trip, including our own events.
+type PGBroker struct {
+	pool  *pgxpool.Pool
+	local *MemoryBroker
+}
+
+// NewPGBroker starts listening on the events channel until ctx is cancelled.
+func NewPGBroker(ctx context.Context, pool *pgxpool.Pool) *PGBroker {
+	b := &PGBroker{pool: pool, local: NewMemoryBroker()}
+	go b.listen(ctx)
+	return b
+}
+
+func (b *PGBroker) Publish(ev Event) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	if _, err := b.pool.Exec(context.Background(), "SELECT pg_notify($1, $2)", pgChannel, string(payload)); err != nil {
+		fmt.Printf("[Events] Failed to publish: %v\n", err)
+	}
+}
+
+func (b *PGBroker) Subscribe(batchID int) (<-chan Event, func()) {
+	return b.local.Subscribe(batchID)
+}
+
+// listen holds a dedicated connection in LISTEN mode, reconnecting after
+// errors, and hands notifications to the local broker.
+func (b *PGBroker) listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := b.listenOnce(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("[Events] Listener error, reconnecting: %v\n", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(2 * time.Second):
+			}
+		}
+	}
+}
+
+func (b *PGBroker) listenOnce(ctx context.Context) error {
+	conn, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgChannel); err != nil {
+		return err
+	}
+	// Don't hand a connection still in LISTEN mode back to the pool
+	defer conn.Conn().Close(context.Background())
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(n.Payload), &ev); err == nil {
+			b.local.Publish(ev)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package fetcher
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// altSvcTransport starts every host on TCP and moves it to HTTP/3 once a
+// response advertises h3 on the same port through Alt-Svc. A host whose
+// QUIC attempt fails goes back to TCP until it advertises h3 again.
+type altSvcTransport struct {
+	tcp *http.Transport
+	h3  *http3.Transport
+
+	mu    sync.Mutex
+	hosts map[string]time.Time // host:port -> when the h3 advertisement expires
+}
+
+func newAltSvcTransport(tcp *http.Transport, h3 *http3.Transport) *altSvcTransport {
+	return &altSvcTransport{tcp: tcp, h3: h3, hosts: make(map[string]time.Time)}
+}
+
+func (t *altSvcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := hostPort(req)
+	if req.URL.Scheme == "https" && t.useH3(key) {
+		resp, err := t.h3.RoundTrip(req)
+		if err == nil {
+			return resp, nil
+		}
+		t.forget(key)
+
+		// Fall back to TCP, which needs a fresh copy of any request body
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+
+	resp, err := t.tcp.RoundTrip(req)
+	if err == nil && req.URL.Scheme == "https" {
+		t.learn(key, resp.Header.Get("Alt-Svc"))
+	}
+	return resp, err
+}
+
+func (t *altSvcTransport) useH3(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	expires, ok := t.hosts[key]
+	if ok && time.Now().After(expires) {
+		delete(t.hosts, key)
+		return false
+	}
+	return ok
+}
+
+func (t *altSvcTransport) forget(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.hosts, key)
+}
+
+// learn records an h3 advertisement for the origin's own port, e.g.
+// `h3=":443"; ma=86400`. Alternatives on other hosts or ports are ignored,
+// and "clear" withdraws an earlier one.
+func (t *altSvcTransport) learn(key, header string) {
+	if header == "" {
+		return
+	}
+	if strings.TrimSpace(header) == "clear" {
+		t.forget(key)
+		return
+	}
+
+	_, port, _ := net.SplitHostPort(key)
+	for _, alt := range strings.Split(header, ",") {
+		params := strings.Split(alt, ";")
+		proto, authority, ok := strings.Cut(strings.TrimSpace(params[0]), "=")
+		if !ok || proto != "h3" || strings.Trim(authority, `"`) != ":"+port {
+			continue
+		}
+
+		maxAge := 24 * time.Hour
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "ma" {
+				if secs, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+					maxAge = time.Duration(secs) * time.Second
+				}
+			}
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		// A crawl touches many hosts; don't let the table grow without bound
+		if len(t.hosts) >= 10000 {
+			clear(t.hosts)
+		}
+		t.hosts[key] = time.Now().Add(maxAge)
+		return
+	}
+}
+
+// hostPort is the request's origin with the port made explicit.
+func hostPort(req *http.Request) string {
+	host, port := req.URL.Hostname(), req.URL.Port()
+	if port == "" {
+		port = "443"
+		if req.URL.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(host), port)
+}
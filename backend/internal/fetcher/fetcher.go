@@ -0,0 +1,189 @@
+// Package fetcher owns the HTTP plumbing every crawl request goes through:
+// one shared, tuned transport with cached DNS, HTTP/2 and optional HTTP/3,
+// and per-phase timings for each fetch.
+package fetcher
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"sentinel/internal/models"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Transport defaults, used for anything Options leaves at zero.
+const (
+	DefaultMaxIdleConns        = 1000
+	DefaultMaxIdleConnsPerHost = 8
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultDNSCacheTTL         = 5 * time.Minute
+	DefaultDialTimeout         = 10 * time.Second
+)
+
+// Options tune the shared transport.
+type Options struct {
+	MaxIdleConns        int // idle connections kept across all hosts
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 leaves it to the scheduler's per-host limit
+	IdleConnTimeout     time.Duration
+	DNSCacheTTL         time.Duration // negative turns the cache off
+	// HTTP3 lets hosts that advertise h3 in Alt-Svc be fetched over QUIC
+	HTTP3 bool
+}
+
+// Fetcher sends requests over a transport shared by every job, so
+// keep-alive connections and resolved addresses outlive a single fetch.
+type Fetcher struct {
+	transport http.RoundTripper
+	tcp       *http.Transport
+	h3        *http3.Transport
+}
+
+func New(opts Options) *Fetcher {
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost <= 0 {
+		opts.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if opts.IdleConnTimeout <= 0 {
+		opts.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if opts.DNSCacheTTL == 0 {
+		opts.DNSCacheTTL = DefaultDNSCacheTTL
+	}
+
+	dialer := &net.Dialer{Timeout: DefaultDialTimeout, KeepAlive: 30 * time.Second}
+	dial := dialer.DialContext
+	if opts.DNSCacheTTL > 0 {
+		dial = newDNSCache(opts.DNSCacheTTL).dialContext(dialer)
+	}
+
+	tcp := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dial,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+
+	f := &Fetcher{transport: tcp, tcp: tcp}
+	if opts.HTTP3 {
+		f.h3 = &http3.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS13}}
+		f.transport = newAltSvcTransport(tcp, f.h3)
+	}
+	return f
+}
+
+// Client returns a client on the shared transport. Clients are cheap; the
+// connections behind them are pooled. With maxRedirects 0 a redirect
+// response is returned as-is instead of being followed.
+func (f *Fetcher) Client(timeout time.Duration, maxRedirects int) *http.Client {
+	return f.client(timeout, func(req *http.Request, via []*http.Request) error {
+		if maxRedirects == 0 {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	})
+}
+
+func (f *Fetcher) client(timeout time.Duration, checkRedirect func(*http.Request, []*http.Request) error) *http.Client {
+	return &http.Client{Transport: f.transport, Timeout: timeout, CheckRedirect: checkRedirect}
+}
+
+// Policy limits a single fetch.
+type Policy struct {
+	Timeout      time.Duration
+	MaxRedirects int
+	MaxBody      int64 // bytes of body read; the rest is discarded
+}
+
+// Result is a finished fetch. Response.Body has already been read into
+// Body and closed; Response.Request is the request that got the final
+// response, so its URL is where any redirects ended up.
+type Result struct {
+	Response *http.Response
+	Body     []byte
+	Timings  models.Timings
+
+	// Redirects lists every redirect response in order, including one the
+	// fetch stopped at rather than followed
+	Redirects []models.RedirectHop
+	Loop      bool // stopped at a redirect back to a URL already visited
+	Truncated bool // stopped after MaxRedirects
+}
+
+// Fetch sends req, reads up to MaxBody bytes of the response and records
+// how long each phase took and the redirects it went through. A redirect
+// loop or a chain longer than MaxRedirects isn't an error: the fetch stops
+// and returns the last redirect response.
+func (f *Fetcher) Fetch(req *http.Request, p Policy) (*Result, error) {
+	t := newTracer()
+	req = req.WithContext(t.context(req.Context()))
+
+	result := &Result{}
+	client := f.client(p.Timeout, func(next *http.Request, via []*http.Request) error {
+		result.Redirects = append(result.Redirects, models.RedirectHop{
+			URL:        via[len(via)-1].URL.String(),
+			StatusCode: next.Response.StatusCode,
+			Location:   next.Response.Header.Get("Location"),
+		})
+		switch {
+		case p.MaxRedirects == 0:
+			return http.ErrUseLastResponse
+		case visited(next.URL, via):
+			result.Loop = true
+			return http.ErrUseLastResponse
+		case len(via) > p.MaxRedirects:
+			result.Truncated = true
+			return http.ErrUseLastResponse
+		}
+		return nil
+	})
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, p.MaxBody))
+	if err != nil {
+		return nil, err
+	}
+	result.Response, result.Body, result.Timings = resp, body, t.finish()
+	return result, nil
+}
+
+// visited reports whether a redirect target was already requested earlier
+// in the chain.
+func visited(target *url.URL, via []*http.Request) bool {
+	for _, req := range via {
+		if req.URL.String() == target.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// CloseIdleConnections closes pooled connections that aren't in use.
+func (f *Fetcher) CloseIdleConnections() {
+	f.tcp.CloseIdleConnections()
+	if f.h3 != nil {
+		f.h3.CloseIdleConnections()
+	}
+}
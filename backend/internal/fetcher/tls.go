@@ -0,0 +1,74 @@
+package fetcher
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"time"
+
+	"sentinel/internal/models"
+)
+
+// TLSInfo summarizes a response's TLS connection and the leaf certificate
+// the server presented, or returns nil for plain HTTP.
+func TLSInfo(cs *tls.ConnectionState) *models.TLSInfo {
+	if cs == nil {
+		return nil
+	}
+	info := &models.TLSInfo{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return info
+	}
+
+	info.Certificate = certificate(cs.PeerCertificates[0])
+	return info
+}
+
+// RejectedTLSInfo returns the certificate a fetch failed to verify, along
+// with the URL that presented it, or nil if err isn't a verification
+// failure. Expired, self-signed and mismatched certificates end up here.
+func RejectedTLSInfo(err error) (string, *models.TLSInfo) {
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) || len(certErr.UnverifiedCertificates) == 0 {
+		return "", nil
+	}
+	var rawURL string
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		rawURL = urlErr.URL
+	}
+	return rawURL, &models.TLSInfo{
+		Certificate: certificate(certErr.UnverifiedCertificates[0]),
+		VerifyError: certErr.Err.Error(),
+	}
+}
+
+func certificate(leaf *x509.Certificate) *models.Certificate {
+	sans := append([]string{}, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range leaf.URIs {
+		sans = append(sans, uri.String())
+	}
+	sum := sha256.Sum256(leaf.Raw)
+
+	return &models.Certificate{
+		Subject:            leaf.Subject.String(),
+		Issuer:             leaf.Issuer.String(),
+		SANs:               sans,
+		NotBefore:          leaf.NotBefore.UTC(),
+		NotAfter:           leaf.NotAfter.UTC(),
+		DaysLeft:           int(time.Until(leaf.NotAfter).Hours() / 24),
+		SerialNumber:       leaf.SerialNumber.Text(16),
+		SignatureAlgorithm: leaf.SignatureAlgorithm.String(),
+		SHA256:             hex.EncodeToString(sum[:]),
+	}
+}
@@ -0,0 +1,105 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"sentinel/internal/models"
+)
+
+// tracer collects phase timings through httptrace. Every phase is summed
+// over the redirects a fetch follows, and TTFB runs from the start of the
+// fetch to the first byte of the final response, so phases that didn't
+// happen (DNS served from the cache, TLS on a reused connection) add nothing.
+type tracer struct {
+	start time.Time
+
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	firstByte    time.Time
+	dns          time.Duration
+	connect      time.Duration
+	tls          time.Duration
+}
+
+func newTracer() *tracer {
+	return &tracer{start: time.Now()}
+}
+
+func (t *tracer) context(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mark(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.add(&t.dns, &t.dnsStart)
+		},
+		// Several addresses may be tried; the phase runs from the first
+		// attempt to the one that connects
+		ConnectStart: func(_, _ string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.add(&t.connect, &t.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.add(&t.tls, &t.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+		},
+	})
+}
+
+func (t *tracer) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*at = time.Now()
+}
+
+// add closes a phase opened with mark and adds its length to total.
+func (t *tracer) add(total *time.Duration, start *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !start.IsZero() {
+		*total += time.Since(*start)
+		*start = time.Time{}
+	}
+}
+
+// finish is called once the body has been read.
+func (t *tracer) finish() models.Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	end := time.Now()
+	timings := models.Timings{
+		DNS:     ms(t.dns),
+		Connect: ms(t.connect),
+		TLS:     ms(t.tls),
+		Total:   ms(end.Sub(t.start)),
+	}
+	if !t.firstByte.IsZero() {
+		timings.TTFB = ms(t.firstByte.Sub(t.start))
+		timings.Download = ms(end.Sub(t.firstByte))
+	}
+	return timings
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
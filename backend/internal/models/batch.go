@@ -0,0 +1,99 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Batch struct {
+	ID         int           `json:"id" db:"id"`
+	UserID     int           `json:"user_id" db:"user_id"`
+	GuestID    string        `json:"-" db:"guest_id"`
+	Name       string        `json:"name" db:"name"`
+	SourceFile string        `json:"source_file,omitempty" db:"source_file"`
+	Settings   BatchSettings `json:"settings" db:"settings"`
+	Total      int           `json:"total" db:"total_jobs"`
+	Completed  int           `json:"completed" db:"completed_jobs"`
+	Failed     int           `json:"failed" db:"failed_jobs"`
+	Blocked    int           `json:"blocked" db:"blocked_jobs"`
+	Dead       int           `json:"dead" db:"dead_jobs"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty" db:"expires_at"`
+
+	// The batch's source is turned into jobs in the background; these say
+	// how far that got and what it found
+	IngestStatus string          `json:"ingest_status" db:"ingest_status"`
+	IngestReport json.RawMessage `json:"ingest_report,omitempty" db:"ingest_report"`
+	IngestError  string          `json:"ingest_error,omitempty" db:"ingest_error"`
+}
+
+// Ingest states of a batch.
+const (
+	IngestRunning = "ingesting"
+	IngestReady   = "ready"
+	IngestFailed  = "failed"
+)
+
+// BatchSettings are the per-batch options stored in the settings JSONB column.
+type BatchSettings struct {
+	JobType string `json:"job_type"`
+	// Extractors to run on every page; empty means the worker defaults
+	Extractors []string `json:"extractors,omitempty"`
+	// Rules are user-defined selectors whose values land under "custom"
+	Rules []ExtractionRule `json:"rules,omitempty"`
+	// Crawl bounds link following for "crawl" batches
+	Crawl *CrawlOptions `json:"crawl,omitempty"`
+	// Fetch shapes the request sent for every job; nil means the defaults
+	Fetch *FetchProfile `json:"fetch,omitempty"`
+}
+
+// FetchProfile is how a batch's pages are requested.
+type FetchProfile struct {
+	UserAgent string            `json:"user_agent,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Cookies   map[string]string `json:"cookies,omitempty"`
+	Method    string            `json:"method,omitempty"` // GET (default) or POST, PUT, PATCH
+	Body      string            `json:"body,omitempty"`   // sent with non-GET methods
+	TimeoutMS int               `json:"timeout_ms,omitempty"`
+	// MaxRedirects caps redirects followed; 0 records the redirect itself.
+	// nil uses the worker default.
+	MaxRedirects *int `json:"max_redirects,omitempty"`
+	// LongRedirectChain flags results that took more redirects than this
+	// to reach their final URL. 0 uses the worker default.
+	LongRedirectChain int `json:"long_redirect_chain,omitempty"`
+	// AcceptTypes lists the media types a page may have, e.g. "text/html"
+	// or "text/*"; other responses fail the job. Empty accepts anything.
+	AcceptTypes []string `json:"accept_types,omitempty"`
+}
+
+// CrawlOptions limit how far a crawl batch spreads from its seed URLs.
+type CrawlOptions struct {
+	MaxDepth int    `json:"max_depth"` // link hops from a seed
+	MaxPages int    `json:"max_pages"` // jobs in the batch, seeds included
+	Scope    string `json:"scope"`     // "host" (default), "domain" or "prefix"
+	// Prefix is a regular expression discovered URLs must match in "prefix" scope
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// ExtractionRule is a named CSS selector or XPath expression applied to every
+// page of a batch.
+type ExtractionRule struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`           // "css" (default) or "xpath"
+	Selector string `json:"selector"`       // CSS selector or XPath expression
+	Mode     string `json:"mode"`           // "text" (default) or "attr"
+	Attr     string `json:"attr,omitempty"` // attribute to read in attr mode
+	Multiple bool   `json:"multiple"`       // all matches instead of the first
+}
+
+// Done reports whether the batch will make no more progress: its ingest
+// failed, or it finished and every job reached a terminal status.
+func (b *Batch) Done() bool {
+	switch b.IngestStatus {
+	case IngestFailed:
+		return true
+	case IngestReady:
+		return b.Completed+b.Failed+b.Blocked+b.Dead == b.Total
+	}
+	return false
+}
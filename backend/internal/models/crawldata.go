@@ -1,12 +1,88 @@
 package models
 
+import "time"
+
 type CrawlData struct {
-	URL             string   `json:"url"`
-	StatusCode      int      `json:"status_code"`
-	ResponseTime    int      `json:"response_time"`
-	ContentHash     string   `json:"content_hash"`
-	Title           string   `json:"title"`
-	H1              string   `json:"h1"`
-	MetaDescription string   `json:"meta_description"`
-	Links           []string `json:"links"`
+	JobID       int    `json:"job_id"`
+	ParentID    int    `json:"parent_id,omitempty"` // page this URL was discovered on
+	Depth       int    `json:"depth,omitempty"`
+	URL         string `json:"url"`
+	FinalURL    string `json:"final_url"` // where redirects, if any, ended up
+	StatusCode  int    `json:"status_code"`
+	Protocol    string `json:"protocol,omitempty"` // HTTP/1.1, HTTP/2.0 or HTTP/3.0
+	ContentHash string `json:"content_hash"`
+	// Headers of the final response
+	Headers map[string][]string `json:"headers,omitempty"`
+	// TLS describes the final response's connection when it was HTTPS
+	TLS *TLSInfo `json:"tls,omitempty"`
+	// Error is set on results stored for a failed fetch, which happens
+	// when only the certificate that failed verification could be captured
+	Error string `json:"error,omitempty"`
+	// Redirects is set when the URL redirected
+	Redirects *RedirectChain `json:"redirects,omitempty"`
+	// Timings break the fetch down by phase
+	Timings Timings `json:"timings"`
+	// Extracted holds one section per extractor the batch ran, keyed by name
+	Extracted     map[string]any    `json:"extracted"`
+	ExtractErrors map[string]string `json:"extract_errors,omitempty"`
+	// Metadata echoes the job's source metadata
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// Timings of one fetch in milliseconds, summed over any redirects it
+// followed. TTFB and Total run from the start of the fetch; Download from
+// the first response byte to the end of the body. Phases a fetch skipped,
+// like DNS for a cached host or TLS on a reused connection, are 0.
+type Timings struct {
+	DNS      float64 `json:"dns_ms"`
+	Connect  float64 `json:"connect_ms"`
+	TLS      float64 `json:"tls_ms"`
+	TTFB     float64 `json:"ttfb_ms"`
+	Download float64 `json:"download_ms"`
+	Total    float64 `json:"total_ms"`
+}
+
+// TLSInfo is the negotiated connection and the certificate the server
+// presented.
+type TLSInfo struct {
+	Version     string       `json:"version,omitempty"` // e.g. "TLS 1.3"
+	CipherSuite string       `json:"cipher_suite,omitempty"`
+	ALPN        string       `json:"alpn,omitempty"` // negotiated protocol, e.g. "h2"
+	Certificate *Certificate `json:"certificate,omitempty"`
+	// VerifyError is why the certificate was rejected; the handshake
+	// stopped there, so no version or cipher was negotiated
+	VerifyError string `json:"verify_error,omitempty"`
+}
+
+// Certificate is the server's leaf certificate.
+type Certificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	SANs      []string  `json:"sans"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	// DaysLeft counts whole days from the fetch to NotAfter
+	DaysLeft           int    `json:"days_left"`
+	SerialNumber       string `json:"serial_number"`
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	SHA256             string `json:"sha256"` // fingerprint of the DER encoding
+}
+
+// RedirectChain is the path a URL took to its final response.
+type RedirectChain struct {
+	Hops []RedirectHop `json:"hops"`
+	// Loop is set when a hop pointed back at a URL already in the chain;
+	// the chain stops there
+	Loop bool `json:"loop,omitempty"`
+	// TooLong is set for chains longer than the batch's long_redirect_chain
+	TooLong bool `json:"too_long,omitempty"`
+	// Truncated is set when max_redirects stopped the chain before it ended
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// RedirectHop is one redirect response along a chain.
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
 }
@@ -3,12 +3,22 @@ package models
 import "time"
 
 type Job struct {
-	ID        int       `json:"id" db:"id"`
-	UserID    int       `json:"user_id" db:"user_id"`
-	URL       string    `json:"url" db:"url"`
-	FilePath  string    `json:"file_path,omitempty" db:"file_path"`
-	JobType   string    `json:"job_type" db:"job_type"`
-	Status    string    `json:"status" db:"status"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID       int `json:"id" db:"id"`
+	UserID   int `json:"user_id" db:"user_id"`
+	BatchID  int `json:"batch_id" db:"batch_id"`
+	ParentID int `json:"parent_id,omitempty" db:"parent_id"`
+	Depth    int `json:"depth" db:"depth"`
+	// CrawlRoot is the seed URL a crawled page descends from; seeds are
+	// their own root
+	CrawlRoot string `json:"crawl_root,omitempty" db:"crawl_root"`
+	URL       string `json:"url" db:"url"`
+	FilePath  string `json:"file_path,omitempty" db:"file_path"`
+	JobType   string `json:"job_type" db:"job_type"`
+	Status    string `json:"status" db:"status"`
+	Attempts  int    `json:"attempts" db:"attempts"`
+	LastError string `json:"last_error,omitempty" db:"last_error"`
+	// Metadata is whatever the URL's source said about it, e.g. sitemap lastmod
+	Metadata  map[string]any `json:"metadata,omitempty" db:"metadata"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
 }
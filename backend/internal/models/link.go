@@ -0,0 +1,9 @@
+package models
+
+// Link is an outbound link found on a page, resolved to an absolute URL.
+type Link struct {
+	URL      string   `json:"url"`
+	Text     string   `json:"text"`
+	Rel      []string `json:"rel,omitempty"` // nofollow, sponsored, ugc, ...
+	Internal bool     `json:"internal"`      // same host as the page, ignoring www.
+}
@@ -0,0 +1,51 @@
+package models
+
+// SecurityAudit grades a page's security headers and cookies, as produced
+// by the "security" extractor.
+type SecurityAudit struct {
+	Score int    `json:"score"` // 0-100
+	Grade string `json:"grade"` // A+, A, B, C, D or F
+	HTTPS bool   `json:"https"`
+
+	HSTS                HSTSCheck     `json:"hsts"`
+	CSP                 CSPCheck      `json:"csp"`
+	XFrameOptions       HeaderCheck   `json:"x_frame_options"`
+	XContentTypeOptions HeaderCheck   `json:"x_content_type_options"`
+	ReferrerPolicy      HeaderCheck   `json:"referrer_policy"`
+	PermissionsPolicy   HeaderCheck   `json:"permissions_policy"`
+	Cookies             []CookieCheck `json:"cookies"`
+}
+
+// HeaderCheck is the verdict on one response header.
+type HeaderCheck struct {
+	Value  string   `json:"value,omitempty"`
+	Pass   bool     `json:"pass"`
+	Issues []string `json:"issues,omitempty"`
+}
+
+type HSTSCheck struct {
+	HeaderCheck
+	MaxAge            int  `json:"max_age"` // seconds
+	IncludeSubDomains bool `json:"include_subdomains"`
+	Preload           bool `json:"preload"`
+}
+
+type CSPCheck struct {
+	HeaderCheck
+	// Directives maps each directive to its source list, merged over every
+	// enforced policy (headers and <meta http-equiv>)
+	Directives   map[string][]string `json:"directives,omitempty"`
+	UnsafeInline bool                `json:"unsafe_inline"` // scripts allow 'unsafe-inline'
+	UnsafeEval   bool                `json:"unsafe_eval"`
+	ReportOnly   bool                `json:"report_only"` // only a Report-Only policy was sent
+}
+
+// CookieCheck is the verdict on one cookie set by the response.
+type CookieCheck struct {
+	Name     string   `json:"name"`
+	Secure   bool     `json:"secure"`
+	HttpOnly bool     `json:"http_only"`
+	SameSite string   `json:"same_site,omitempty"` // Strict, Lax or None; empty if unset
+	Pass     bool     `json:"pass"`
+	Issues   []string `json:"issues,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+// StructuredData is the machine-readable metadata found on a page, as
+// produced by the "structured" extractor.
+type StructuredData struct {
+	Canonical string              `json:"canonical,omitempty"`
+	Hreflang  []HreflangLink      `json:"hreflang,omitempty"`
+	Robots    RobotsDirectives    `json:"robots"`
+	OpenGraph map[string][]string `json:"open_graph,omitempty"` // og:* without the prefix
+	Twitter   map[string]string   `json:"twitter,omitempty"`    // twitter:* without the prefix
+	JSONLD    []map[string]any    `json:"json_ld,omitempty"`    // @graph entries flattened
+	Microdata []MicrodataItem     `json:"microdata,omitempty"`
+}
+
+type HreflangLink struct {
+	Lang string `json:"lang"`
+	URL  string `json:"url"`
+}
+
+// RobotsDirectives merges the robots meta tags and the X-Robots-Tag header.
+type RobotsDirectives struct {
+	// Meta is keyed by the tag's name: "robots", "googlebot", ...
+	Meta     map[string][]string `json:"meta,omitempty"`
+	Header   []string            `json:"header,omitempty"`
+	NoIndex  bool                `json:"noindex"`
+	NoFollow bool                `json:"nofollow"`
+}
+
+// MicrodataItem is one schema.org itemscope. Property values are strings or
+// nested MicrodataItems.
+type MicrodataItem struct {
+	Type       []string         `json:"type,omitempty"`
+	ID         string           `json:"id,omitempty"`
+	Properties map[string][]any `json:"properties"`
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"crypto/subtle"
+	"sentinel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ownsBatch reports whether the caller may see a batch. Users own the batches
+// they created; guests own the ones created under their session ID.
+func ownsBatch(c *gin.Context, batch *models.Batch) bool {
+	val, exists := c.Get("user_id")
+	if !exists {
+		return false
+	}
+	if userID := int(val.(uint)); userID != 0 {
+		return batch.UserID == userID
+	}
+
+	guestID := c.GetString("guest_id")
+	return batch.UserID == 0 && guestID != "" &&
+		subtle.ConstantTimeCompare([]byte(guestID), []byte(batch.GuestID)) == 1
+}
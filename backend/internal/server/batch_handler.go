@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sentinel/internal/database"
+	"sentinel/internal/models"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadBatch resolves the batch a request refers to. New routes pass its ID
+// as :id; the legacy /api/jobs/:filename routes pass the uploaded filename.
+// Batches the caller doesn't own answer 404, same as missing ones, so IDs
+// and filenames can't be probed.
+func (s *Server) loadBatch(c *gin.Context) (*models.Batch, bool) {
+	var batch *models.Batch
+	var err error
+
+	// The legacy routes also accept a batch ID in place of the filename
+	idParam := c.Param("id")
+	if idParam == "" {
+		if _, err := strconv.Atoi(c.Param("filename")); err == nil {
+			idParam = c.Param("filename")
+		}
+	}
+
+	if idParam != "" {
+		id, convErr := strconv.Atoi(idParam)
+		if convErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch id"})
+			return nil, false
+		}
+		batch, err = database.GetBatch(s.WorkerPool.DB, id)
+	} else {
+		batch, err = database.GetBatchBySourceFile(s.WorkerPool.DB, "./uploads/"+c.Param("filename"))
+	}
+
+	if err != nil || !ownsBatch(c, batch) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch not found"})
+		return nil, false
+	}
+	return batch, true
+}
+
+// batchFilename is the name results are downloaded under.
+func batchFilename(b *models.Batch) string {
+	if b.SourceFile != "" {
+		return filepath.Base(b.SourceFile)
+	}
+	return fmt.Sprintf("batch_%d", b.ID)
+}
+
+func (s *Server) ListBatchesHandler(c *gin.Context) {
+	val, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userID := int(val.(uint))
+
+	// Guests don't have stored batches list (per requirement)
+	if userID == 0 {
+		c.JSON(http.StatusOK, gin.H{"batches": []models.Batch{}})
+		return
+	}
+
+	batches, err := database.ListUserBatches(s.WorkerPool.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch batches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"batches": batches})
+}
+
+// createBatchRequest is the JSON body of POST /api/batches, for callers
+// that have their URLs in memory rather than in a file.
+type createBatchRequest struct {
+	Name string   `json:"name"`
+	URLs []string `json:"urls"`
+	batchOptions
+}
+
+// MaxBatchURLs caps the URLs accepted in one POST /api/batches body.
+const MaxBatchURLs = 100000
+
+func (s *Server) CreateBatchHandler(c *gin.Context) {
+	batch, ok := s.newBatch(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 32<<20)
+	var req createBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request body: %s", err)})
+		return
+	}
+	if len(req.URLs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "urls must list at least one URL"})
+		return
+	}
+	if len(req.URLs) > MaxBatchURLs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many urls (max %d per batch)", MaxBatchURLs)})
+		return
+	}
+
+	if !slices.ContainsFunc(req.URLs, func(u string) bool { return isValidURL(strings.TrimSpace(u)) }) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No valid http(s) URLs in urls"})
+		return
+	}
+
+	settings, err := req.settings()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	batch.Name = strings.TrimSpace(req.Name)
+	if batch.Name == "" {
+		batch.Name = fmt.Sprintf("%d URLs (%s)", len(req.URLs), time.Now().UTC().Format(time.RFC3339))
+	}
+	batch.Settings = settings
+	urls := plainURLs(req.URLs)
+	if !s.startBatch(c, batch, func(context.Context) urlSeq { return urls }) {
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"batch_id": batch.ID,
+		"name":     batch.Name,
+	})
+}
+
+func (s *Server) GetBatchHandler(c *gin.Context) {
+	batch, ok := s.loadBatch(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+func (s *Server) BatchStatusHandler(c *gin.Context) {
+	batch, ok := s.loadBatch(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, batchStatus(batch))
+}
+
+func batchStatus(batch *models.Batch) gin.H {
+	status := "processing"
+	switch {
+	case batch.IngestStatus == models.IngestFailed:
+		status = "failed"
+	case batch.Done():
+		status = "completed"
+	}
+
+	resp := gin.H{
+		"batch_id":  batch.ID,
+		"total":     batch.Total,
+		"completed": batch.Completed,
+		"failed":    batch.Failed,
+		"blocked":   batch.Blocked,
+		"dead":      batch.Dead,
+		"status":    status,
+		"ingest":    batch.IngestStatus,
+	}
+	if batch.IngestReport != nil {
+		resp["urls"] = batch.IngestReport
+	}
+	if batch.IngestError != "" {
+		resp["error"] = batch.IngestError
+	}
+	return resp
+}
+
+func (s *Server) BatchDownloadHandler(c *gin.Context) {
+	batch, ok := s.loadBatch(c)
+	if !ok {
+		return
+	}
+
+	var filter database.ResultFilter
+	if v := c.Query("cert_expires_within"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cert_expires_within must be a number of days"})
+			return
+		}
+		filter.CertExpiresWithinDays = &days
+	}
+
+	results, err := database.GetJobResults(s.WorkerPool.DB, batch.ID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch results"})
+		return
+	}
+
+	if len(results) == 0 {
+		// An empty filtered download is an answer, not a missing one
+		if filter.CertExpiresWithinDays == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No results found (or job pending)"})
+			return
+		}
+		results = []models.CrawlData{}
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_results.json", batchFilename(batch)))
+	c.Header("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(c.Writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(results); err != nil {
+		// Log error
+	}
+}
+
+func (s *Server) BatchMetricsHandler(c *gin.Context) {
+	batch, ok := s.loadBatch(c)
+	if !ok {
+		return
+	}
+
+	metrics, err := database.GetJobMetrics(s.WorkerPool.DB, batch.ID)
+	if err != nil {
+		fmt.Printf("[DEBUG] Metrics Err: %v | Batch: %d\n", err, batch.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+func (s *Server) RetryBatchHandler(c *gin.Context) {
+	batch, ok := s.loadBatch(c)
+	if !ok {
+		return
+	}
+
+	requeued, err := database.RequeueDeadJobs(s.WorkerPool.DB, batch.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to requeue jobs"})
+		return
+	}
+	s.WorkerPool.Wake()
+
+	c.JSON(http.StatusOK, gin.H{"requeued": requeued})
+}
+
+func (s *Server) DeleteBatchHandler(c *gin.Context) {
+	val, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userID := int(val.(uint))
+
+	if userID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Guests cannot delete jobs"})
+		return
+	}
+
+	batch, ok := s.loadBatch(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteBatch(s.WorkerPool.DB, batch.ID, userID); err != nil {
+		fmt.Printf("[DEBUG] Delete error: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete batch"})
+		return
+	}
+
+	if batch.SourceFile != "" {
+		os.Remove(batch.SourceFile)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Batch deleted"})
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"sentinel/internal/database"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchEventsHandler streams a batch's progress as Server-Sent Events:
+// a "job" event per settled URL, a "progress" event with the counters (at
+// most once a second), and a final "done" event once every job settled.
+func (s *Server) BatchEventsHandler(c *gin.Context) {
+	batch, ok := s.loadBatch(c)
+	if !ok {
+		return
+	}
+
+	// Subscribe before the first counter read so no completion slips between
+	events, unsubscribe := s.WorkerPool.Events.Subscribe(batch.ID)
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// sendProgress re-reads the counters and reports whether the batch is done
+	sendProgress := func() bool {
+		current, err := database.GetBatch(s.WorkerPool.DB, batch.ID)
+		if err != nil {
+			return false
+		}
+		status := batchStatus(current)
+		c.SSEvent("progress", status)
+		if current.Done() {
+			c.SSEvent("done", status)
+			return true
+		}
+		return false
+	}
+
+	c.Status(http.StatusOK)
+	if sendProgress() {
+		return
+	}
+
+	throttle := time.NewTicker(time.Second)
+	defer throttle.Stop()
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	dirty := false
+
+	c.Stream(func(w io.Writer) bool {
+		// Let shutdown finish instead of waiting on long-lived streams
+		if s.draining.Load() {
+			return false
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case ev := <-events:
+			c.SSEvent("job", ev)
+			dirty = true
+		case <-throttle.C:
+			if dirty {
+				dirty = false
+				return !sendProgress()
+			}
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+		}
+		return true
+	})
+}
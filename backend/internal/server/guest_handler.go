@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sentinel/internal/database"
+	"sentinel/internal/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GuestHandler starts an anonymous session: a signed token with its own
+// guest ID, upload quota and expiry.
+func (s *Server) GuestHandler(c *gin.Context) {
+	guestID, err := utils.GenerateSessionID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create guest session"})
+		return
+	}
+
+	token, expiresAt, err := utils.GenerateGuestToken(guestID, s.GuestQuota, s.GuestTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        token,
+		"expires_at":   expiresAt,
+		"upload_quota": s.GuestQuota,
+	})
+}
+
+// RunGuestCleanup purges expired guest batches, with their jobs, results and
+// uploaded files, every interval until ctx is cancelled.
+func (s *Server) RunGuestCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		files, err := database.PurgeExpiredBatches(ctx, s.WorkerPool.DB)
+		if err != nil {
+			fmt.Printf("[Cleanup] Failed to purge expired batches: %v\n", err)
+			continue
+		}
+		for _, f := range files {
+			os.Remove(f)
+		}
+		if len(files) > 0 {
+			fmt.Printf("[Cleanup] Purged %d expired guest batches\n", len(files))
+		}
+	}
+}
@@ -1,17 +1,49 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
+	"regexp"
 	"sentinel/internal/utils"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+var accessTokenParam = regexp.MustCompile(`([?&]access_token=)[^&]*`)
+
+// LogFormatter is gin's default access log line with the access_token query
+// parameter masked, so event stream URLs don't write bearer tokens to the log.
+func LogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		accessTokenParam.ReplaceAllString(param.Path, "${1}REDACTED"),
+		param.ErrorMessage,
+	)
+}
+
 func (s *Server) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		//  Authorization header
 		authHeader := c.GetHeader("Authorization")
+		// EventSource can't set headers, so event streams may pass the token in the query
+		if authHeader == "" && c.GetHeader("Accept") == "text/event-stream" && c.Query("access_token") != "" {
+			authHeader = "Bearer " + c.Query("access_token")
+		}
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			return
@@ -24,18 +56,21 @@ func (s *Server) AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Validate the token
-		if parts[1] == "guest-session" {
-			c.Set("user_id", uint(0)) // 0 indicates guest
-			c.Next()
-			return
-		}
-
 		claims, err := utils.ValidateToken(parts[1])
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			return
 		}
 
+		if claims.GuestID != "" {
+			c.Set("user_id", uint(0)) // 0 indicates guest
+			c.Set("guest_id", claims.GuestID)
+			c.Set("guest_quota", claims.UploadQuota)
+			c.Set("guest_expires_at", claims.ExpiresAt.Time)
+			c.Next()
+			return
+		}
+
 		// Save the UserID into the context
 		c.Set("user_id", claims.UserID)
 
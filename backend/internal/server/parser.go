@@ -1,109 +1,202 @@
 package server
 
 import (
-	"bufio"
-	"encoding/csv"
-	"encoding/json"
+	"archive/zip"
+	"encoding/xml"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"strings"
 
 	"github.com/ledongthuc/pdf"
 )
 
-func processFile(filePath string) ([]string, error) {
-
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".txt":
-		return parseTextFile(filePath)
-	case ".csv":
-		return parseCSVFile(filePath)
-	case ".json":
-		return parseJSONFile(filePath)
-	case ".pdf":
-		return parsePDFFile(filePath)
-	default:
-		return nil, fmt.Errorf("unsupported file format: %s", ext)
-	}
-}
-
-func parseTextFile(filePath string) ([]string, error) {
+// maxTextSize caps how much of a Word document's text is scanned for URLs.
+const maxTextSize = 64 << 20
 
-	file, err := os.Open(filePath)
+func parseText(r io.Reader, emit emitFunc) error {
+	err := scanURLs(r, func(u string) bool {
+		return emit(sourceURL{URL: u})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("Error parsing your txt file: %s", err)
-	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	datas := []string{}
-	for scanner.Scan() == true {
-		datas = append(datas, scanner.Text())
-
+		return fmt.Errorf("Error reading your txt file: %w", err)
 	}
-	return datas, nil
+	return nil
 }
 
-func parseCSVFile(filePath string) ([]string, error) {
-	file, err := os.Open(filePath)
+// parsePDF collects the URLs in the text of every page plus the targets of
+// link annotations, which often hold URLs the visible text shortens.
+func parsePDF(ra io.ReaderAt, size int64, emit emitFunc) error {
+	r, err := pdf.NewReader(ra, size)
 	if err != nil {
-		return nil, fmt.Errorf("Error parsing your txt file: %s", err)
+		return fmt.Errorf("Error parsing pdf: %s", err)
 	}
-	defer file.Close()
 
-	records, err := csv.NewReader(file).ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("Error parsing your csv file: %s", err)
+	stopped := false
+	emitURL := func(u string) bool {
+		stopped = !emit(sourceURL{URL: u})
+		return !stopped
 	}
+	for i := 1; i <= r.NumPage() && !stopped; i++ {
+		record := r.Page(i)
+		if record.V.IsNull() {
+			continue
+		}
+		text, err := pdfPageText(record)
+		if err != nil {
 
-	datas := []string{}
-	for _, data := range records {
-		datas = append(datas, data[0])
-
+			return fmt.Errorf("Error reading page %d: %s", i, err)
+		}
+		scanURLs(strings.NewReader(text), emitURL)
+		for _, u := range pdfLinkAnnotations(record) {
+			if stopped || !emitURL(u) {
+				break
+			}
+		}
 	}
 
-	return datas, nil
+	return nil
 
 }
 
-func parseJSONFile(filePath string) ([]string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("Error parsing your json file: %s", err)
+// pdfPageText rebuilds the page's text line by line, so URLs wrapped at the
+// end of a line can be joined again. Fragments on one row are concatenated
+// without spaces since PDFs often split a single URL into several runs.
+func pdfPageText(page pdf.Page) (string, error) {
+	rows, err := page.GetTextByRow()
+	if err != nil || len(rows) == 0 {
+		return page.GetPlainText(nil)
 	}
-	defer file.Close()
 
-	datas := []string{}
-	decoder := json.NewDecoder(file)
+	var b strings.Builder
+	for _, row := range rows {
+		for _, word := range row.Content {
+			b.WriteString(word.S)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
 
-	err = decoder.Decode(&datas)
-	if err != nil {
-		return nil, fmt.Errorf("Error decoding json: %s", err)
+func pdfLinkAnnotations(page pdf.Page) []string {
+	var urls []string
+	annots := page.V.Key("Annots")
+	for i := 0; i < annots.Len(); i++ {
+		annot := annots.Index(i)
+		if annot.Key("Subtype").Name() != "Link" {
+			continue
+		}
+		action := annot.Key("A")
+		if action.Key("S").Name() != "URI" {
+			continue
+		}
+		if uri := strings.TrimSpace(action.Key("URI").RawString()); uri != "" {
+			urls = append(urls, uri)
+		}
 	}
+	return urls
+}
 
-	return datas, nil
+// parseDocx reads the body text and external hyperlinks of a Word
+// document. A .docx is a zip whose text lives in word/document.xml and whose
+// hyperlink targets live in the relationships part.
+func parseDocx(ra io.ReaderAt, size int64, emit emitFunc) error {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("Error parsing your docx file: %s", err)
+	}
 
+	var urls []string
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			text, err := docxText(f)
+			if err != nil {
+				return fmt.Errorf("Error parsing your docx file: %s", err)
+			}
+			urls = append(urls, extractURLs(text)...)
+		case "word/_rels/document.xml.rels":
+			links, err := docxHyperlinks(f)
+			if err != nil {
+				return fmt.Errorf("Error parsing your docx file: %s", err)
+			}
+			urls = append(urls, links...)
+		}
+	}
+	for _, u := range urls {
+		if !emit(sourceURL{URL: u}) {
+			break
+		}
+	}
+	return nil
 }
 
-func parsePDFFile(filePath string) ([]string, error) {
-	f, r, err := pdf.Open(filePath)
+// docxText flattens document.xml to plain text, one line per paragraph.
+func docxText(f *zip.File) (string, error) {
+	rc, err := f.Open()
 	if err != nil {
-		return nil, fmt.Errorf("Error parsing pdf: %s", err)
+		return "", err
 	}
-
-	defer f.Close()
-	datas := []string{}
-	for i := 1; i <= r.NumPage(); i++ {
-		record := r.Page(i)
-		text, err := record.GetPlainText(nil)
+	defer rc.Close()
+
+	var b strings.Builder
+	decoder := xml.NewDecoder(io.LimitReader(rc, maxTextSize))
+	inText := false
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return b.String(), nil
+		}
 		if err != nil {
-
-			return nil, fmt.Errorf("Error reading page %d: %s", i, err)
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte(' ')
+			case "br":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
 		}
-		datas = append(datas, text)
 	}
+}
 
-	return datas, nil
+func docxHyperlinks(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var rels struct {
+		Relationships []struct {
+			Type       string `xml:"Type,attr"`
+			Target     string `xml:"Target,attr"`
+			TargetMode string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&rels); err != nil {
+		return nil, err
+	}
 
+	var urls []string
+	for _, rel := range rels.Relationships {
+		if rel.TargetMode == "External" && strings.HasSuffix(rel.Type, "/hyperlink") {
+			urls = append(urls, rel.Target)
+		}
+	}
+	return urls, nil
 }
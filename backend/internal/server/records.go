@@ -0,0 +1,304 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// urlFieldNames are the column and field names tried, case-insensitively,
+// when the caller doesn't say where the URL is.
+var urlFieldNames = []string{"url", "link", "href", "website", "uri", "loc"}
+
+// parseCSV reads one URL per row. A header row is detected (or required,
+// when urlColumn names a column) and every other column of a row is kept as
+// metadata, keyed by its header or by "col_<index>" without one.
+func parseCSV(r io.Reader, urlColumn string, emit emitFunc) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	// Header detection needs to see the first two rows
+	var head [][]string
+	for len(head) < 2 {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Error parsing your csv file: %w", err)
+		}
+		head = append(head, record)
+	}
+	if len(head) == 0 {
+		return nil
+	}
+	if len(head[0]) > 0 {
+		head[0][0] = strings.TrimPrefix(head[0][0], "\ufeff")
+	}
+
+	var header []string
+	if csvHasHeader(head, urlColumn) {
+		header, head = head[0], head[1:]
+	}
+	col, err := csvURLColumn(header, head, urlColumn)
+	if err != nil {
+		return err
+	}
+
+	emitRow := func(record []string) bool {
+		if col >= len(record) {
+			return true
+		}
+		entry := sourceURL{URL: record[col]}
+		for i, value := range record {
+			if i == col {
+				continue
+			}
+			if entry.Metadata == nil {
+				entry.Metadata = map[string]any{}
+			}
+			key := fmt.Sprintf("col_%d", i)
+			if i < len(header) && header[i] != "" {
+				key = header[i]
+			}
+			entry.Metadata[key] = value
+		}
+		return emit(entry)
+	}
+
+	for _, record := range head {
+		if !emitRow(record) {
+			return nil
+		}
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error parsing your csv file: %w", err)
+		}
+		if !emitRow(record) {
+			return nil
+		}
+	}
+}
+
+// csvHasHeader treats the first row as a header when urlColumn names a
+// column, or when it holds no URL while the row after it does.
+func csvHasHeader(records [][]string, urlColumn string) bool {
+	if urlColumn != "" {
+		if _, err := strconv.Atoi(urlColumn); err != nil {
+			return true
+		}
+	}
+	rowHasURL := func(row []string) bool {
+		for _, cell := range row {
+			if isValidURL(strings.TrimSpace(cell)) {
+				return true
+			}
+		}
+		return false
+	}
+	if rowHasURL(records[0]) {
+		return false
+	}
+	return len(records) == 1 || rowHasURL(records[1])
+}
+
+func csvURLColumn(header []string, records [][]string, urlColumn string) (int, error) {
+	if urlColumn != "" {
+		if idx, err := strconv.Atoi(urlColumn); err == nil {
+			if idx < 0 {
+				return 0, fmt.Errorf("url_column must be a column name or a 0-based index")
+			}
+			return idx, nil
+		}
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), urlColumn) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("url_column %q not found in the CSV header", urlColumn)
+	}
+
+	// Otherwise look for a conventional header, then for the first column
+	// holding a URL
+	for _, want := range urlFieldNames {
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), want) {
+				return i, nil
+			}
+		}
+	}
+	if len(records) > 0 {
+		for i, cell := range records[0] {
+			if isValidURL(strings.TrimSpace(cell)) {
+				return i, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// parseJSON reads a JSON array or NDJSON stream whose items are URL
+// strings or objects, decoding one item at a time. For objects the URL is
+// found at urlPath (a JSON pointer or dotted path, defaulting to a
+// conventional top-level field) and the object's other top-level fields are
+// kept as metadata.
+func parseJSON(r io.Reader, urlPath string, emit emitFunc) error {
+	path, err := parseJSONPath(urlPath)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(r)
+	first, err := peekNonSpace(reader)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading your json file: %w", err)
+	}
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	emitItem := func(item any) bool {
+		switch v := item.(type) {
+		case string:
+			return emit(sourceURL{URL: v})
+		case map[string]any:
+			u, key := jsonURL(v, path)
+			entry := sourceURL{URL: u}
+			for k, field := range v {
+				if k == key {
+					continue
+				}
+				if entry.Metadata == nil {
+					entry.Metadata = map[string]any{}
+				}
+				entry.Metadata[k] = field
+			}
+			return emit(entry)
+		}
+		return true
+	}
+
+	// A top-level array is walked element by element; anything else is read
+	// as a stream of values, which covers NDJSON
+	if first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("Error decoding json: %w", err)
+		}
+		for decoder.More() {
+			var item any
+			if err := decoder.Decode(&item); err != nil {
+				return fmt.Errorf("Error decoding json: %w", err)
+			}
+			if !emitItem(item) {
+				return nil
+			}
+		}
+		return nil
+	}
+	for {
+		var item any
+		err := decoder.Decode(&item)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error decoding json: %w", err)
+		}
+		if !emitItem(item) {
+			return nil
+		}
+	}
+}
+
+// parseJSONPath splits a JSON pointer (RFC 6901) or a dotted path into
+// segments. An empty path means "look for a conventional field".
+func parseJSONPath(path string) ([]string, error) {
+	switch {
+	case path == "":
+		return nil, nil
+	case strings.HasPrefix(path, "/"):
+		segments := strings.Split(path[1:], "/")
+		for i, seg := range segments {
+			segments[i] = strings.ReplaceAll(strings.ReplaceAll(seg, "~1", "/"), "~0", "~")
+		}
+		return segments, nil
+	default:
+		segments := strings.Split(path, ".")
+		for _, seg := range segments {
+			if seg == "" {
+				return nil, fmt.Errorf("url_path %q has an empty segment", path)
+			}
+		}
+		return segments, nil
+	}
+}
+
+// jsonURL resolves the URL of an object, also returning the top-level key
+// it came from when the path is a single field, so it isn't repeated in the
+// metadata.
+func jsonURL(obj map[string]any, path []string) (string, string) {
+	if len(path) == 0 {
+		for _, want := range urlFieldNames {
+			for k, v := range obj {
+				if s, ok := v.(string); ok && strings.EqualFold(k, want) {
+					return s, k
+				}
+			}
+		}
+		return "", ""
+	}
+
+	var cur any = obj
+	for _, seg := range path {
+		switch node := cur.(type) {
+		case map[string]any:
+			cur = node[seg]
+		case []any:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return "", ""
+			}
+			cur = node[idx]
+		default:
+			return "", ""
+		}
+	}
+	s, _ := cur.(string)
+	if len(path) == 1 {
+		return s, path[0]
+	}
+	return s, ""
+}
+
+// peekNonSpace returns the first significant byte of r without consuming it,
+// skipping leading whitespace and a UTF-8 byte order mark.
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	if bom, err := r.Peek(3); err == nil && bytes.Equal(bom, []byte{0xef, 0xbb, 0xbf}) {
+		r.Discard(3)
+	}
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			r.Discard(1)
+		default:
+			return b[0], nil
+		}
+	}
+}
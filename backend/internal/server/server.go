@@ -1,9 +1,14 @@
 package server
 
 import (
+	"context"
 	"os"
 	"sentinel/internal/email"
 	"sentinel/internal/worker"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -13,9 +18,47 @@ type Server struct {
 	WorkerPool   *worker.Pool
 	EmailClient  *email.Client
 	GoogleConfig *oauth2.Config
+
+	// Anonymous guest sessions: how long a guest token lives and how many
+	// batches it may create
+	GuestTTL   time.Duration
+	GuestQuota int
+
+	// Upload limits: bytes on the wire, and bytes once a .gz or .zip upload
+	// is unpacked. Both are enforced while the upload streams in.
+	MaxUploadSize   int64
+	MaxUnpackedSize int64
+
+	// draining is set once shutdown starts; new uploads are refused
+	draining atomic.Bool
+
+	// ingestCtx is cancelled by StopIngest to abort uploads still being
+	// turned into jobs in the background; ingests tracks them
+	ingestCtx  context.Context
+	stopIngest context.CancelFunc
+	ingests    sync.WaitGroup
 }
 
 func NewServer(workerPool *worker.Pool, emailClient *email.Client) *Server {
+	guestTTL, err := time.ParseDuration(os.Getenv("GUEST_SESSION_TTL"))
+	if err != nil {
+		guestTTL = 2 * time.Hour
+	}
+	guestQuota, err := strconv.Atoi(os.Getenv("GUEST_UPLOAD_QUOTA"))
+	if err != nil {
+		guestQuota = 3
+	}
+
+	maxUpload, err := strconv.ParseInt(os.Getenv("UPLOAD_MAX_BYTES"), 10, 64)
+	if err != nil || maxUpload <= 0 {
+		maxUpload = 100 << 20
+	}
+	maxUnpacked, err := strconv.ParseInt(os.Getenv("UPLOAD_MAX_UNPACKED_BYTES"), 10, 64)
+	if err != nil || maxUnpacked <= 0 {
+		maxUnpacked = 1 << 30
+	}
+
+	ingestCtx, stopIngest := context.WithCancel(context.Background())
 	return &Server{
 		WorkerPool:  workerPool,
 		EmailClient: emailClient,
@@ -26,5 +69,33 @@ func NewServer(workerPool *worker.Pool, emailClient *email.Client) *Server {
 			Endpoint:     google.Endpoint,
 			Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email"},
 		},
+		GuestTTL:        guestTTL,
+		GuestQuota:      guestQuota,
+		MaxUploadSize:   maxUpload,
+		MaxUnpackedSize: maxUnpacked,
+		ingestCtx:       ingestCtx,
+		stopIngest:      stopIngest,
+	}
+}
+
+// Drain makes the server refuse new uploads while in-flight work finishes.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// StopIngest waits for uploads still being turned into jobs until ctx is
+// done, then cancels the rest and waits for their batches to be rolled
+// back. Call it once the HTTP server has stopped taking uploads.
+func (s *Server) StopIngest(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.ingests.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
 	}
+	s.stopIngest()
+	<-done
 }
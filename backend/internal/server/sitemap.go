@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"sentinel/internal/sitemap"
+	"sentinel/internal/worker"
+)
+
+// parseSitemap reads an uploaded sitemap. Uploaded indexes are expanded by
+// fetching the sitemaps they list. A gzipped sitemap counts against the
+// upload's unpack budget as it inflates.
+func (s *Server) parseSitemap(ctx context.Context, r io.Reader, budget *unpackBudget, emit emitFunc) error {
+	entries, children, err := sitemap.Parse(r, budget.reader)
+	if err != nil {
+		return fmt.Errorf("Error reading your sitemap: %w", err)
+	}
+	if len(children) > 0 {
+		entries, err = s.sitemapExpander().ExpandIndex(ctx, children)
+		if err != nil && !errors.Is(err, sitemap.ErrLimit) {
+			return err
+		}
+	}
+	emitSitemapEntries(entries, emit)
+	return nil
+}
+
+// expandSitemapURL fetches a sitemap by URL. With discover set, rawURL names
+// a site instead and its sitemaps are taken from robots.txt `Sitemap:` lines,
+// falling back to /sitemap.xml.
+func (s *Server) expandSitemapURL(ctx context.Context, rawURL string, discover bool) urlSeq {
+	return streamURLs(func(emit emitFunc) error {
+		u, err := url.Parse(rawURL)
+		if err != nil || !isValidURL(rawURL) {
+			return fmt.Errorf("invalid sitemap_url %q", rawURL)
+		}
+
+		sitemaps := []string{rawURL}
+		if discover {
+			sitemaps = s.WorkerPool.Robots.Get(u).Sitemaps
+			if len(sitemaps) == 0 {
+				sitemaps = []string{u.Scheme + "://" + u.Host + "/sitemap.xml"}
+			}
+		}
+
+		expander := s.sitemapExpander()
+		for _, sm := range sitemaps {
+			found, err := expander.Expand(ctx, sm)
+			if !emitSitemapEntries(found, emit) || errors.Is(err, sitemap.ErrLimit) {
+				return nil
+			}
+			if err != nil {
+				// Other sitemaps from robots.txt may still work
+				if discover && len(sitemaps) > 1 {
+					fmt.Printf("[Sitemap] Skipping %v\n", err)
+					continue
+				}
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Server) sitemapExpander() *sitemap.Expander {
+	exp := sitemap.NewExpander(s.WorkerPool.UserAgent)
+	exp.Client = s.WorkerPool.Fetcher.Client(exp.Client.Timeout, worker.DefaultMaxRedirects)
+	return exp
+}
+
+func emitSitemapEntries(entries []sitemap.Entry, emit emitFunc) bool {
+	for _, e := range entries {
+		if !emit(sourceURL{URL: e.Loc, Metadata: e.Metadata()}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourceURL is a URL read from an upload along with anything the source
+// said about it, which is kept as job metadata.
+type sourceURL struct {
+	URL      string
+	Metadata map[string]any
+}
+
+// urlSeq streams the URLs of a source straight into job creation, so a
+// multi-million line file is never held in memory. A read or parse error is
+// yielded once, as the last element.
+type urlSeq = iter.Seq2[sourceURL, error]
+
+// emitFunc receives URLs from a parser; returning false stops the parse.
+type emitFunc func(sourceURL) bool
+
+func plainURLs(urls []string) urlSeq {
+	return func(yield func(sourceURL, error) bool) {
+		for _, u := range urls {
+			if !yield(sourceURL{URL: u}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// streamURLs adapts a parser to a urlSeq.
+func streamURLs(parse func(emit emitFunc) error) urlSeq {
+	return func(yield func(sourceURL, error) bool) {
+		stopped := false
+		emit := func(u sourceURL) bool {
+			if !stopped {
+				stopped = !yield(u, nil)
+			}
+			return !stopped
+		}
+		if err := parse(emit); err != nil && !stopped {
+			yield(sourceURL{}, err)
+		}
+	}
+}
+
+// parseOptions tell the structured parsers where the URL lives in a record.
+type parseOptions struct {
+	URLColumn string // CSV column name or 0-based index
+	URLPath   string // JSON pointer ("/site/url") or dotted path ("site.url")
+}
+
+// errTooLarge is returned once an upload unpacks to more than the server
+// allows, which stops zip and gzip bombs mid-stream.
+var errTooLarge = errors.New("upload is too large once decompressed")
+
+// unpackBudget counts the decompressed bytes of every stream in one upload.
+type unpackBudget struct {
+	remaining int64
+}
+
+func (b *unpackBudget) reader(r io.Reader) io.Reader {
+	return &budgetReader{r: r, budget: b}
+}
+
+type budgetReader struct {
+	r      io.Reader
+	budget *unpackBudget
+}
+
+func (br *budgetReader) Read(p []byte) (int, error) {
+	n, err := br.r.Read(p)
+	br.budget.remaining -= int64(n)
+	if br.budget.remaining < 0 {
+		return n, errTooLarge
+	}
+	return n, err
+}
+
+// processFile streams the URLs of an uploaded file.
+func (s *Server) processFile(ctx context.Context, filePath string, opts parseOptions) urlSeq {
+	return streamURLs(func(emit emitFunc) error {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("Error opening your file: %s", err)
+		}
+		defer file.Close()
+
+		budget := &unpackBudget{remaining: s.MaxUnpackedSize}
+		return s.parseStream(ctx, filepath.Base(filePath), file, opts, budget, false, emit)
+	})
+}
+
+// parseStream parses r according to name's extension. A .gz or .zip upload
+// is unpacked one level and its contents parsed in turn.
+func (s *Server) parseStream(ctx context.Context, name string, r io.Reader, opts parseOptions, budget *unpackBudget, nested bool, emit emitFunc) error {
+	ext := strings.ToLower(filepath.Ext(name))
+	if nested && (ext == ".gz" || ext == ".zip") {
+		return fmt.Errorf("nested archives are not supported: %s", name)
+	}
+
+	switch ext {
+	case ".gz":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("Error reading your gzip file: %s", err)
+		}
+		defer gz.Close()
+		return s.parseStream(ctx, strings.TrimSuffix(name, filepath.Ext(name)), budget.reader(gz), opts, budget, true, emit)
+	case ".zip":
+		return s.parseZip(ctx, r, opts, budget, emit)
+	case ".txt", ".md":
+		return parseText(r, emit)
+	case ".csv":
+		return parseCSV(r, opts.URLColumn, emit)
+	case ".json", ".ndjson", ".jsonl":
+		return parseJSON(r, opts.URLPath, emit)
+	case ".xml":
+		return s.parseSitemap(ctx, r, budget, emit)
+	case ".pdf", ".docx":
+		// Both formats need random access
+		ra, size, cleanup, err := readerAt(r)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		if ext == ".pdf" {
+			return parsePDF(ra, size, emit)
+		}
+		return parseDocx(ra, size, emit)
+	default:
+		return fmt.Errorf("unsupported file format: %s", ext)
+	}
+}
+
+// parseZip parses every supported file in a zip archive; anything else in
+// it (folders, READMEs, macOS metadata) is skipped.
+func (s *Server) parseZip(ctx context.Context, r io.Reader, opts parseOptions, budget *unpackBudget, emit emitFunc) error {
+	ra, size, cleanup, err := readerAt(r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("Error reading your zip file: %s", err)
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || !supportedInZip(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("Error reading %s from your zip file: %s", f.Name, err)
+		}
+		err = s.parseStream(ctx, f.Name, budget.reader(rc), opts, budget, true, emit)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// supportedUpload reports whether parseStream can read a file by this name,
+// so an unusable upload is refused before a batch is created for it.
+func supportedUpload(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip":
+		return true
+	case ".gz":
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return supportedInZip(name)
+}
+
+func supportedInZip(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".md", ".csv", ".json", ".ndjson", ".jsonl", ".xml", ".pdf", ".docx":
+		return true
+	}
+	return false
+}
+
+// readerAt gives random access to r, spooling it to a temporary file unless
+// it already is one.
+func readerAt(r io.Reader) (io.ReaderAt, int64, func(), error) {
+	if f, ok := r.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		return f, info.Size(), func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "sentinel-upload-*")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	return tmp, size, cleanup, nil
+}
@@ -1,12 +1,21 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/maphash"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"sentinel/internal/database"
 	"sentinel/internal/models"
+	"sentinel/internal/worker"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,78 +23,441 @@ import (
 )
 
 func (s *Server) UploadHandler(c *gin.Context) {
+	batch, ok := s.newBatch(c)
+	if !ok {
+		return
+	}
+
+	// The URLs come from an uploaded file or a sitemap fetched by URL
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.MaxUploadSize)
+	file, fileErr := c.FormFile("document")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(fileErr, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Upload exceeds the %d byte limit", s.MaxUploadSize)})
+		return
+	}
+	sitemapURL := strings.TrimSpace(c.PostForm("sitemap_url"))
+	if fileErr != nil && sitemapURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no file received"})
+		return
+	}
+
+	settings, err := parseBatchSettings(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var name, filename, dst string
+	var urls func(context.Context) urlSeq
+	if fileErr == nil {
+		name = filepath.Base(file.Filename)
+		if !supportedUpload(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported file format: %s", filepath.Ext(name))})
+			return
+		}
+		dst, err = saveUpload(file, name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to save file"})
+			return
+		}
+		filename = filepath.Base(dst)
+
+		opts := parseOptions{
+			URLColumn: strings.TrimSpace(c.PostForm("url_column")),
+			URLPath:   strings.TrimSpace(c.PostForm("url_path")),
+		}
+		urls = func(ctx context.Context) urlSeq { return s.processFile(ctx, dst, opts) }
+	} else {
+		name = sitemapURL
+		discover := c.PostForm("discover_sitemaps") == "true"
+		urls = func(ctx context.Context) urlSeq { return s.expandSitemapURL(ctx, sitemapURL, discover) }
+	}
+
+	batch.Name = name
+	batch.SourceFile = dst
+	batch.Settings = settings
+	if !s.startBatch(c, batch, urls) {
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "File uploaded successfully. Processing in background.",
+		"filename": filename,
+		"batch_id": batch.ID,
+	})
+}
+
+// saveUpload stores an uploaded file under ./uploads. The name gets a
+// timestamp and a random part, and the file is created exclusively, so two
+// uploads of the same name at the same moment can't overwrite each other
+// or collide on batches.source_file.
+func saveUpload(file *multipart.FileHeader, name string) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll("./uploads", 0750); err != nil {
+		return "", err
+	}
+	// CreateTemp swaps the last "*" for the random part
+	pattern := fmt.Sprintf("%d_*_%s", time.Now().Unix(), strings.ReplaceAll(name, "*", "_"))
+	dst, err := os.CreateTemp("./uploads", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return "./uploads/" + filepath.Base(dst.Name()), nil
+}
+
+// newBatch checks that the caller may start another batch and returns one
+// owned by them. It writes the error response itself when they may not.
+func (s *Server) newBatch(c *gin.Context) (*models.Batch, bool) {
+	if s.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down, please retry shortly"})
+		return nil, false
+	}
+
 	val, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
+		return nil, false
 	}
-	userID := int(val.(uint))
+	batch := &models.Batch{UserID: int(val.(uint))}
 
-	// Check limit for non-guest users
-	if userID != 0 {
-		count, err := database.CountUserFiles(s.WorkerPool.DB, userID)
+	// Guests are limited by the quota in their token
+	if batch.UserID == 0 {
+		batch.GuestID = c.GetString("guest_id")
+		count, err := database.CountGuestBatches(s.WorkerPool.DB, batch.GuestID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check limits"})
-			return
+			return nil, false
 		}
-		if count >= 10 {
-			c.JSON(http.StatusForbidden, gin.H{"error": "File limit reached (max 10). Please delete some files from your profile."})
-			return
+		if count >= c.GetInt("guest_quota") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Guest upload quota reached. Sign up to upload more files."})
+			return nil, false
 		}
+		exp := c.GetTime("guest_expires_at")
+		batch.ExpiresAt = &exp
+		return batch, true
 	}
 
-	file, err := c.FormFile("document")
+	// Check limit for non-guest users
+	count, err := database.CountUserBatches(s.WorkerPool.DB, batch.UserID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no file received"})
-		return
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check limits"})
+		return nil, false
+	}
+	if count >= 10 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File limit reached (max 10). Please delete some files from your profile."})
+		return nil, false
 	}
+	return batch, true
+}
 
-	// Unique filename
-	filename := fmt.Sprintf("%d_%s", time.Now().Unix(), filepath.Base(file.Filename))
-	dst := "./uploads/" + filename
+// startBatch stores the batch, answers 202 with its ID and turns its URLs
+// into jobs in the background, so a source too big to ingest within a
+// client or proxy timeout still makes it in. Shutdown waits for ingests
+// still running, cancelling them once its deadline passes.
+func (s *Server) startBatch(c *gin.Context, batch *models.Batch, urls func(context.Context) urlSeq) bool {
+	if err := database.CreateBatch(s.WorkerPool.DB, batch); err != nil {
+		if batch.SourceFile != "" {
+			os.Remove(batch.SourceFile)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create batch"})
+		return false
+	}
+
+	s.ingests.Add(1)
+	go func() {
+		defer s.ingests.Done()
+		s.ingest(s.ingestCtx, batch, urls(s.ingestCtx))
+	}()
+	return true
+}
 
-	if err := c.SaveUploadedFile(file, dst); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to save file"})
+// ingest streams a batch's URLs into jobs and records the outcome on the
+// batch. If the source turns out to be unreadable part way through or the
+// server shuts down first, whatever it queued is dropped again, so a failed
+// ingest never leaves half a batch running.
+func (s *Server) ingest(ctx context.Context, batch *models.Batch, urls urlSeq) {
+	start := time.Now()
+	report, err := s.createJobs(ctx, batch, urls)
+	report.IngestMS = time.Since(start).Milliseconds()
+
+	// ctx may be cancelled by now; the outcome must be recorded regardless
+	if err == nil {
+		if err := database.FinishIngest(context.Background(), s.WorkerPool.DB, batch.ID, report); err != nil {
+			fmt.Printf("Failed to record ingest of batch %d: %v\n", batch.ID, err)
+		}
 		return
 	}
 
-	urls, err := processFile(dst)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Error processing file: %s", err)})
-		// Clean up file if processing fails?
+	var srcErr *sourceError
+	var msg string
+	switch {
+	case ctx.Err() != nil:
+		msg = "Server shut down before the upload was processed, please retry"
+	case errors.Is(err, errTooLarge):
+		msg = fmt.Sprintf("Upload exceeds the %d byte limit once decompressed", s.MaxUnpackedSize)
+	case errors.As(err, &srcErr):
+		msg = fmt.Sprintf("Error processing file: %s", srcErr.err)
+	default:
+		fmt.Printf("Failed to create jobs for batch %d: %v\n", batch.ID, err)
+		msg = "Failed to create jobs"
+	}
+	if err := database.FailIngest(context.Background(), s.WorkerPool.DB, batch.ID, report, msg); err != nil {
+		fmt.Printf("Failed to roll back batch %d after ingest error: %v\n", batch.ID, err)
+	}
+	if batch.SourceFile != "" {
+		os.Remove(batch.SourceFile)
+	}
+}
+
+// sourceError marks a failure reading the URLs, as opposed to storing them.
+type sourceError struct {
+	err error
+}
+
+func (e *sourceError) Error() string { return e.err.Error() }
+func (e *sourceError) Unwrap() error { return e.err }
+
+// Reasons a found URL doesn't become a job.
+const (
+	rejectInvalid     = "invalid_url"
+	rejectScheme      = "unsupported_scheme"
+	rejectDuplicate   = "duplicate"
+	rejectCrawlBudget = "crawl_budget"
+)
+
+// maxRejectedSamples bounds the example rejections echoed to the client.
+const maxRejectedSamples = 20
+
+type rejectedURL struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// ingestReport accounts for every URL a source produced.
+type ingestReport struct {
+	Found    int            `json:"found"`
+	Accepted int            `json:"accepted"`
+	Rejected map[string]int `json:"rejected"`
+	Samples  []rejectedURL  `json:"rejected_samples,omitempty"`
+	IngestMS int64          `json:"ingest_ms"`
+}
+
+func (r *ingestReport) reject(u, reason string, n int) {
+	if n <= 0 {
 		return
 	}
+	r.Rejected[reason] += n
+	if len(r.Samples) < maxRejectedSamples && u != "" {
+		r.Samples = append(r.Samples, rejectedURL{URL: u, Reason: reason})
+	}
+}
+
+// createJobs validates the URLs and inserts them in chunks, waking the
+// worker pool after each one so crawling starts before ingestion finishes.
+func (s *Server) createJobs(ctx context.Context, batch *models.Batch, urls urlSeq) (*ingestReport, error) {
+	report := &ingestReport{Rejected: map[string]int{}}
+	// Hashes rather than the URLs themselves keep de-duplication of a
+	// multi-million line upload to a few bytes per URL
+	seed := maphash.MakeSeed()
+	seen := make(map[uint64]struct{})
+	chunk := make([]models.Job, 0, database.JobBatchSize)
 
-	// Concurrent creation
-	go func(urlList []string, uid int, fPath string) {
-		for _, u := range urlList {
-			cleanU := strings.TrimSpace(u)
-			if isValidURL(cleanU) {
-				job := models.Job{
-					URL:      cleanU,
-					UserID:   uid,
-					Status:   "pending",
-					FilePath: fPath,
-					JobType:  "web",
-				}
-				if err := database.CreateJob(s.WorkerPool.DB, &job); err == nil {
-					s.WorkerPool.JobChan <- job
-				}
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		// Crawl seeds go through the frontier like discovered links do, so
+		// they count against the page budget
+		var n int
+		if crawl := batch.Settings.Crawl; crawl != nil {
+			jobs, err := database.EnqueueCrawlJobs(ctx, s.WorkerPool.DB, batch.ID, chunk, crawl.MaxPages)
+			if err != nil {
+				return err
+			}
+			n = len(jobs)
+			if over := len(chunk) - n; over > 0 {
+				report.reject(chunk[len(chunk)-1].URL, rejectCrawlBudget, over)
 			}
+		} else {
+			ids, err := database.CreateJobs(ctx, s.WorkerPool.DB, chunk)
+			if err != nil {
+				return err
+			}
+			n = len(ids)
 		}
-	}(urls, userID, dst)
+		report.Accepted += n
+		chunk = chunk[:0]
+		s.WorkerPool.Wake()
+		return nil
+	}
 
-	c.JSON(http.StatusAccepted, gin.H{
-		"message":     "File uploaded successfully. Processing in background.",
-		"filename":    filename,
-		"total_found": len(urls),
-	})
+	for u, err := range urls {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if err != nil {
+			return report, &sourceError{err: err}
+		}
+		report.Found++
+		cleanU := strings.TrimSpace(u.URL)
+		if reason := checkURL(cleanU); reason != "" {
+			report.reject(cleanU, reason, 1)
+			continue
+		}
+		if batch.Settings.Crawl != nil {
+			cleanU = worker.NormalizeURL(cleanU)
+		}
+		key := maphash.String(seed, cleanU)
+		if _, dup := seen[key]; dup {
+			report.reject(cleanU, rejectDuplicate, 1)
+			continue
+		}
+		seen[key] = struct{}{}
+
+		chunk = append(chunk, models.Job{
+			URL:      cleanU,
+			UserID:   batch.UserID,
+			BatchID:  batch.ID,
+			Status:   "pending",
+			FilePath: batch.SourceFile,
+			JobType:  batch.Settings.JobType,
+			Metadata: u.Metadata,
+		})
+		if len(chunk) == database.JobBatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, flush()
+}
+
+// batchOptions are the per-batch options both ingestion routes accept.
+type batchOptions struct {
+	JobType    string                  `json:"job_type"`
+	Extractors []string                `json:"extractors"`
+	Rules      []models.ExtractionRule `json:"rules"`
+	Crawl      *models.CrawlOptions    `json:"crawl"`
+	Fetch      *models.FetchProfile    `json:"fetch"`
+}
+
+// parseBatchSettings reads the optional batch options sent with an upload.
+func parseBatchSettings(c *gin.Context) (models.BatchSettings, error) {
+	opts := batchOptions{JobType: c.PostForm("job_type")}
+
+	if raw := c.PostForm("crawl"); raw != "" {
+		opts.Crawl = &models.CrawlOptions{}
+		if err := json.Unmarshal([]byte(raw), opts.Crawl); err != nil {
+			return models.BatchSettings{}, fmt.Errorf("crawl must be a JSON object: %w", err)
+		}
+	}
+
+	if raw := c.PostForm("fetch"); raw != "" {
+		opts.Fetch = &models.FetchProfile{}
+		if err := json.Unmarshal([]byte(raw), opts.Fetch); err != nil {
+			return models.BatchSettings{}, fmt.Errorf("fetch must be a JSON object: %w", err)
+		}
+	}
+
+	if raw := c.PostForm("extractors"); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts.Extractors = append(opts.Extractors, name)
+			}
+		}
+	}
+
+	if raw := c.PostForm("rules"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.Rules); err != nil {
+			return models.BatchSettings{}, fmt.Errorf("rules must be a JSON array: %w", err)
+		}
+	}
+
+	return opts.settings()
+}
+
+// settings validates the options and fills in defaults.
+func (o batchOptions) settings() (models.BatchSettings, error) {
+	settings := models.BatchSettings{JobType: "web"}
+
+	// Crawl options on their own imply a crawl
+	jobType := o.JobType
+	if jobType == "" && o.Crawl != nil {
+		jobType = "crawl"
+	}
+	switch jobType {
+	case "", "web":
+	case "crawl":
+		settings.JobType = jobType
+		settings.Crawl = o.Crawl
+		if settings.Crawl == nil {
+			settings.Crawl = &models.CrawlOptions{}
+		}
+		if err := worker.ValidateCrawl(settings.Crawl); err != nil {
+			return settings, err
+		}
+	default:
+		return settings, fmt.Errorf("unknown job_type %q (want web or crawl)", jobType)
+	}
+
+	if o.Fetch != nil {
+		settings.Fetch = o.Fetch
+		if err := worker.ValidateFetchProfile(settings.Fetch); err != nil {
+			return settings, err
+		}
+	}
+
+	if len(o.Extractors) > 0 {
+		settings.Extractors = o.Extractors
+		if err := worker.ValidateExtractors(settings.Extractors); err != nil {
+			return settings, err
+		}
+	}
+
+	if len(o.Rules) > 0 {
+		settings.Rules = o.Rules
+		if err := worker.ValidateRules(settings.Rules); err != nil {
+			return settings, err
+		}
+		// Rules only take effect through the custom extractor
+		if len(settings.Extractors) == 0 {
+			settings.Extractors = append(settings.Extractors, worker.DefaultExtractors...)
+		}
+		if !slices.Contains(settings.Extractors, "custom") {
+			settings.Extractors = append(settings.Extractors, "custom")
+		}
+	}
+
+	return settings, nil
 }
 
 func isValidURL(toTest string) bool {
+	return checkURL(toTest) == ""
+}
+
+// checkURL returns why a URL can't be crawled, or "" if it can.
+func checkURL(toTest string) string {
 	u, err := url.ParseRequestURI(toTest)
 	if err != nil {
-		return false
+		return rejectInvalid
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return rejectScheme
+	}
+	if u.Host == "" {
+		return rejectInvalid
 	}
-	return u.Scheme == "http" || u.Scheme == "https"
+	return ""
 }
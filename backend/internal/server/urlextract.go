@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// urlPattern finds http(s) URLs in running text. Parentheses are allowed so
+// Wikipedia-style URLs survive; unbalanced ones are trimmed afterwards.
+var urlPattern = regexp.MustCompile(`(?i)https?://[^\s<>"'\x60{}|\\^\[\]]+`)
+
+// maxLineSize caps a single line of text; longer lines fail the parse
+// rather than being buffered without bound.
+const maxLineSize = 1 << 20
+
+// extractURLs returns the http(s) URLs found anywhere in text, in order of
+// appearance.
+func extractURLs(text string) []string {
+	var urls []string
+	scanURLs(strings.NewReader(text), func(u string) bool {
+		urls = append(urls, u)
+		return true
+	})
+	return urls
+}
+
+// scanURLs streams the http(s) URLs found in r to emit, in order of
+// appearance, until emit returns false. URLs broken over two lines, as PDF
+// text extraction and hard-wrapped plain text produce, are joined back
+// together, so one line of lookahead is kept.
+func scanURLs(r io.Reader, emit func(string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxLineSize)
+
+	line, haveLine := "", false
+	for {
+		next, haveNext := "", scanner.Scan()
+		if haveNext {
+			next = strings.TrimSuffix(scanner.Text(), "\r")
+		}
+		if haveLine {
+			for _, u := range lineURLs(line, &next, haveNext) {
+				if !emit(u) {
+					return nil
+				}
+			}
+		}
+		if !haveNext {
+			return scanner.Err()
+		}
+		line, haveLine = next, true
+	}
+}
+
+// lineURLs returns the URLs on line. If the last one runs to the end of the
+// line and carries on at the start of next, the continuation is moved from
+// next onto it.
+func lineURLs(line string, next *string, haveNext bool) []string {
+	var urls []string
+	locs := urlPattern.FindAllStringIndex(line, -1)
+	for j, loc := range locs {
+		u := line[loc[0]:loc[1]]
+
+		if j == len(locs)-1 && haveNext && loc[1] == len(strings.TrimRight(line, " \t")) {
+			if cont := urlContinuation(u, *next); cont != "" {
+				u += cont
+				*next = strings.TrimLeft(*next, " \t")[len(cont):]
+			}
+		}
+		if u = trimURL(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// urlContinuation returns the leading run of next that continues url, or ""
+// if next looks like ordinary text or a new address. A wrapped URL resumes
+// without a space and its continuation looks like part of a path or query,
+// not a word. Ending on a separator isn't enough on its own: prose often
+// follows a URL that ends in a slash.
+func urlContinuation(url, next string) string {
+	next = strings.TrimLeft(next, " \t")
+	run := next
+	if i := strings.IndexAny(run, " \t"); i >= 0 {
+		run = run[:i]
+	}
+	if run == "" || urlPattern.MatchString(run) {
+		return ""
+	}
+	if loc := urlPattern.FindStringIndex("http://x" + run); loc == nil || loc[1] != len("http://x"+run) {
+		return ""
+	}
+	if !urlStructure(run) {
+		return ""
+	}
+
+	// Once the URL has reached its path, a run that opens with a host name
+	// is a bare address of its own, e.g. www.example.com/page
+	_, rest, _ := strings.Cut(url, "://")
+	if strings.Contains(rest, "/") {
+		host, _, isPath := strings.Cut(run, "/")
+		if strings.HasPrefix(strings.ToLower(run), "www.") || (isPath && hasExtension(host)) {
+			return ""
+		}
+	}
+	return run
+}
+
+// urlStructure reports whether run has a path or query separator in it or
+// ends in something like a file extension or top-level domain.
+func urlStructure(run string) bool {
+	run = trimURL(run)
+	return strings.ContainsAny(run, "/=&?%#") || hasExtension(run)
+}
+
+// hasExtension reports whether s ends in a dot and two or more letters or
+// digits, as "report.pdf" and "example.com" do but "e.g" doesn't.
+func hasExtension(s string) bool {
+	i := strings.LastIndexByte(s, '.')
+	if i <= 0 || len(s)-i < 3 {
+		return false
+	}
+	for _, c := range s[i+1:] {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// trimURL drops punctuation that belongs to the surrounding sentence rather
+// than to the URL: trailing periods and commas, and unbalanced brackets.
+func trimURL(u string) string {
+	for u != "" {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,;:!?*'\"", last) >= 0:
+			u = u[:len(u)-1]
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"):
+			u = u[:len(u)-1]
+		default:
+			return u
+		}
+	}
+	return u
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "running text",
+			text: "See https://example.com/a and http://example.org/b?x=1 for more.",
+			want: []string{"https://example.com/a", "http://example.org/b?x=1"},
+		},
+		{
+			name: "sentence punctuation",
+			text: "Visit https://example.com/page. Or https://example.com/other, or (https://example.com/paren)!",
+			want: []string{"https://example.com/page", "https://example.com/other", "https://example.com/paren"},
+		},
+		{
+			name: "balanced parentheses kept",
+			text: "https://en.wikipedia.org/wiki/Go_(programming_language)",
+			want: []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"},
+		},
+		{
+			name: "quotes and angle brackets",
+			text: `<a href="https://example.com/q">x</a> <https://example.com/angle>`,
+			want: []string{"https://example.com/q", "https://example.com/angle"},
+		},
+		{
+			name: "scheme is case-insensitive",
+			text: "HTTPS://EXAMPLE.COM/UP",
+			want: []string{"HTTPS://EXAMPLE.COM/UP"},
+		},
+		{
+			name: "no urls",
+			text: "ftp://example.com www.example.com example.com",
+			want: nil,
+		},
+		{
+			name: "crlf line endings",
+			text: "https://example.com/a\r\nhttps://example.com/b\r\n",
+			want: []string{"https://example.com/a", "https://example.com/b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractURLs(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractURLs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractURLsWrapped(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "cut after a slash",
+			text: "https://example.com/docs/\nguide/intro.html",
+			want: []string{"https://example.com/docs/guide/intro.html"},
+		},
+		{
+			name: "cut inside the query",
+			text: "https://example.com/search?q=go&\npage=2 is the second page",
+			want: []string{"https://example.com/search?q=go&page=2"},
+		},
+		{
+			name: "continuation with path structure",
+			text: "https://example.com/very/long/pa\nth/to/file.html",
+			want: []string{"https://example.com/very/long/path/to/file.html"},
+		},
+		{
+			name: "indented continuation",
+			text: "https://example.com/a/\n   b/c.html",
+			want: []string{"https://example.com/a/b/c.html"},
+		},
+		{
+			name: "next line is a plain word",
+			text: "https://example.com/page\nfollows here",
+			want: []string{"https://example.com/page"},
+		},
+		{
+			name: "next line starts with a url",
+			text: "https://example.com/a/\nhttps://example.com/b",
+			want: []string{"https://example.com/a/", "https://example.com/b"},
+		},
+		{
+			name: "url not at the end of the line",
+			text: "https://example.com/a/ is one\npath/to/elsewhere",
+			want: []string{"https://example.com/a/"},
+		},
+		{
+			name: "file name after a slash",
+			text: "https://example.com/files/\nreport.pdf",
+			want: []string{"https://example.com/files/report.pdf"},
+		},
+		{
+			name: "host cut mid-name",
+			text: "https://www.exam\nple.com/page",
+			want: []string{"https://www.example.com/page"},
+		},
+		{
+			name: "prose after a trailing slash",
+			text: "See https://example.com/\nfor details.",
+			want: []string{"https://example.com/"},
+		},
+		{
+			name: "abbreviation after a trailing slash",
+			text: "https://example.com/docs/\ni.e., the manual",
+			want: []string{"https://example.com/docs/"},
+		},
+		{
+			name: "question mark ends the sentence",
+			text: "Have you seen https://example.com/?\nIt is new.",
+			want: []string{"https://example.com/"},
+		},
+		{
+			name: "bare www address on the next line",
+			text: "https://example.com/docs/\nwww.example.org/page",
+			want: []string{"https://example.com/docs/"},
+		},
+		{
+			name: "bare host on the next line",
+			text: "https://example.com/docs/\nexample.org/page",
+			want: []string{"https://example.com/docs/"},
+		},
+		{
+			name: "trailing period ends the sentence",
+			text: "Read https://example.com/page.\nNext sentence.",
+			want: []string{"https://example.com/page"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractURLs(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractURLs(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanURLsStops(t *testing.T) {
+	var got []string
+	err := scanURLs(strings.NewReader("https://a.example/\nhttps://b.example/\nhttps://c.example/"), func(u string) bool {
+		got = append(got, u)
+		return len(got) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://a.example/", "https://b.example/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scanURLs() emitted %q, want %q", got, want)
+	}
+}
+
+func TestScanURLsLongLine(t *testing.T) {
+	text := "https://example.com/" + strings.Repeat("a", maxLineSize)
+	if err := scanURLs(strings.NewReader(text), func(string) bool { return true }); err == nil {
+		t.Error("expected an error for a line over maxLineSize")
+	}
+}
+
+func TestTrimURL(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https://example.com/a", "https://example.com/a"},
+		{"https://example.com/a.", "https://example.com/a"},
+		{"https://example.com/a.,;:!?", "https://example.com/a"},
+		{`https://example.com/a"'`, "https://example.com/a"},
+		{"https://example.com/a)", "https://example.com/a"},
+		{"https://example.com/a_(b)", "https://example.com/a_(b)"},
+		{"https://example.com/a_(b)).", "https://example.com/a_(b)"},
+		{"https://example.com/a?", "https://example.com/a"},
+		{"...", ""},
+	}
+	for _, tt := range tests {
+		if got := trimURL(tt.in); got != tt.want {
+			t.Errorf("trimURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package sitemap reads XML sitemaps and sitemap indexes, following indexes
+// recursively to the page URLs they list.
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is one <url> of a urlset.
+type Entry struct {
+	Loc        string
+	LastMod    string
+	ChangeFreq string
+	Priority   *float64
+	// Sitemap is the sitemap the entry was listed in; empty for uploads
+	Sitemap string
+}
+
+// Metadata returns the entry's optional fields in the shape stored on jobs.
+func (e Entry) Metadata() map[string]any {
+	meta := map[string]any{}
+	if e.LastMod != "" {
+		meta["lastmod"] = e.LastMod
+	}
+	if e.ChangeFreq != "" {
+		meta["changefreq"] = e.ChangeFreq
+	}
+	if e.Priority != nil {
+		meta["priority"] = *e.Priority
+	}
+	if e.Sitemap != "" {
+		meta["sitemap"] = e.Sitemap
+	}
+	return meta
+}
+
+type document struct {
+	XMLName  xml.Name
+	URLs     []xmlURL `xml:"url"`
+	Sitemaps []xmlLoc `xml:"sitemap"`
+}
+
+type xmlURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+}
+
+type xmlLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// MaxSize is the largest sitemap the protocol allows, uncompressed.
+const MaxSize = 50 << 20
+
+// ErrTooLarge is returned when a fetched sitemap is over MaxSize.
+var ErrTooLarge = errors.New("sitemap is over 50MB uncompressed")
+
+// Parse decodes a urlset or sitemap index, gunzipping it first if needed.
+// It returns the page entries of a urlset or the child sitemap URLs of an
+// index. The caller bounds r; limit wraps the gunzipped stream, which a
+// small compressed file could otherwise inflate without bound.
+func Parse(r io.Reader, limit func(io.Reader) io.Reader) (entries []Entry, children []string, err error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid gzip sitemap: %w", err)
+		}
+		defer gz.Close()
+		r = limit(gz)
+	} else {
+		r = br
+	}
+
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid sitemap XML: %w", err)
+	}
+
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.URLs {
+			e := Entry{
+				Loc:        strings.TrimSpace(u.Loc),
+				LastMod:    strings.TrimSpace(u.LastMod),
+				ChangeFreq: strings.ToLower(strings.TrimSpace(u.ChangeFreq)),
+			}
+			if p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64); err == nil {
+				e.Priority = &p
+			}
+			if e.Loc != "" {
+				entries = append(entries, e)
+			}
+		}
+	case "sitemapindex":
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				children = append(children, loc)
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("not a sitemap: unexpected <%s> root", doc.XMLName.Local)
+	}
+	return entries, children, nil
+}
+
+// ErrLimit is returned alongside the entries collected so far when an
+// expansion hits MaxURLs or MaxSitemaps.
+var ErrLimit = errors.New("sitemap limit reached")
+
+// Expander fetches sitemaps over HTTP and follows indexes.
+type Expander struct {
+	UserAgent   string
+	Client      *http.Client
+	MaxSitemaps int // sitemap files fetched per expansion
+	MaxURLs     int // entries returned per expansion
+	MaxDepth    int // index nesting followed
+}
+
+func NewExpander(userAgent string) *Expander {
+	return &Expander{
+		UserAgent:   userAgent,
+		Client:      &http.Client{Timeout: 30 * time.Second},
+		MaxSitemaps: 500,
+		MaxURLs:     50000,
+		MaxDepth:    5,
+	}
+}
+
+// Expand fetches the sitemap at rawURL and returns every page it leads to.
+func (e *Expander) Expand(ctx context.Context, rawURL string) ([]Entry, error) {
+	x := &expansion{Expander: e, seen: make(map[string]bool)}
+	err := x.fetch(ctx, rawURL, 0)
+	return x.entries, err
+}
+
+// ExpandIndex follows the child sitemaps of an index that was parsed locally,
+// e.g. from an upload.
+func (e *Expander) ExpandIndex(ctx context.Context, children []string) ([]Entry, error) {
+	x := &expansion{Expander: e, seen: make(map[string]bool)}
+	for _, child := range children {
+		if err := x.fetchChild(ctx, child, 1); err != nil {
+			return x.entries, err
+		}
+	}
+	return x.entries, nil
+}
+
+type expansion struct {
+	*Expander
+	seen    map[string]bool
+	fetched int
+	entries []Entry
+}
+
+func (x *expansion) fetch(ctx context.Context, rawURL string, depth int) error {
+	if x.seen[rawURL] || depth > x.MaxDepth {
+		return nil
+	}
+	x.seen[rawURL] = true
+	if x.fetched >= x.MaxSitemaps {
+		return ErrLimit
+	}
+	x.fetched++
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", x.UserAgent)
+
+	resp, err := x.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch sitemap %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch sitemap %s: status %d", rawURL, resp.StatusCode)
+	}
+
+	// The protocol caps a sitemap at 50MB uncompressed, so the compressed
+	// body can't be any larger either
+	entries, children, err := Parse(limitSize(resp.Body), limitSize)
+	if err != nil {
+		return fmt.Errorf("%s: %w", rawURL, err)
+	}
+	for _, entry := range entries {
+		if len(x.entries) >= x.MaxURLs {
+			return ErrLimit
+		}
+		entry.Sitemap = rawURL
+		x.entries = append(x.entries, entry)
+	}
+	for _, child := range children {
+		if ref, err := url.Parse(child); err == nil {
+			if base, err := url.Parse(rawURL); err == nil {
+				child = base.ResolveReference(ref).String()
+			}
+		}
+		if err := x.fetchChild(ctx, child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sizeLimiter fails with ErrTooLarge once more than MaxSize bytes are read,
+// rather than cutting the sitemap short.
+type sizeLimiter struct {
+	r         io.Reader
+	remaining int64
+}
+
+func limitSize(r io.Reader) io.Reader {
+	return &sizeLimiter{r: r, remaining: MaxSize}
+}
+
+func (l *sizeLimiter) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// fetchChild fetches a sitemap listed in an index. One broken child doesn't
+// sink the rest of the index; only hitting a limit stops the expansion.
+func (x *expansion) fetchChild(ctx context.Context, rawURL string, depth int) error {
+	err := x.fetch(ctx, rawURL, depth)
+	if err != nil && !errors.Is(err, ErrLimit) && ctx.Err() == nil {
+		fmt.Printf("[Sitemap] Skipping %v\n", err)
+		return nil
+	}
+	return err
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"math/big"
 )
@@ -24,3 +25,12 @@ func GenerateOTP(length int) (string, error) {
 
 	return string(code), nil
 }
+
+// GenerateSessionID returns a random 256-bit identifier, hex encoded.
+func GenerateSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
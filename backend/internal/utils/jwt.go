@@ -11,6 +11,9 @@ import (
 
 type AuthClaims struct {
 	UserID uint `json:"user_id"`
+	// Guest tokens carry an anonymous ID and upload quota instead of a user
+	GuestID     string `json:"guest_id,omitempty"`
+	UploadQuota int    `json:"upload_quota,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -38,6 +41,30 @@ func GenerateToken(userID uint) (string, error) {
 	return token.SignedString([]byte(secret))
 }
 
+// GenerateGuestToken issues a short-lived anonymous token for a guest session.
+func GenerateGuestToken(guestID string, quota int, ttl time.Duration) (string, time.Time, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return "", time.Time{}, errors.New("JWT_SECRET not set")
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	claims := AuthClaims{
+		GuestID:     guestID,
+		UploadQuota: quota,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "guest:" + guestID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "sentinel-api",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(secret))
+	return signed, expiresAt, err
+}
+
 // ValidateToken parses the token string and returns the claims if valid.
 func ValidateToken(tokenString string) (*AuthClaims, error) {
 	secret := os.Getenv("JWT_SECRET")
@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"sentinel/internal/database"
+	"sentinel/internal/models"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Crawl defaults, used when a batch doesn't set its own bounds.
+const (
+	DefaultCrawlDepth = 3
+	DefaultCrawlPages = 500
+	MaxCrawlPages     = 50000
+)
+
+// ValidateCrawl fills in defaults and rejects bounds we won't run.
+func ValidateCrawl(opts *models.CrawlOptions) error {
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = DefaultCrawlDepth
+	}
+	if opts.MaxPages == 0 {
+		opts.MaxPages = DefaultCrawlPages
+	}
+	if opts.Scope == "" {
+		opts.Scope = "host"
+	}
+	if opts.MaxDepth < 0 {
+		return fmt.Errorf("crawl max_depth must be positive")
+	}
+	if opts.MaxPages < 0 || opts.MaxPages > MaxCrawlPages {
+		return fmt.Errorf("crawl max_pages must be between 1 and %d", MaxCrawlPages)
+	}
+
+	switch opts.Scope {
+	case "host", "domain":
+	case "prefix":
+		if opts.Prefix == "" {
+			return fmt.Errorf("crawl scope %q needs a prefix pattern", opts.Scope)
+		}
+		if _, err := regexp.Compile(opts.Prefix); err != nil {
+			return fmt.Errorf("crawl prefix: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown crawl scope %q (want host, domain or prefix)", opts.Scope)
+	}
+	return nil
+}
+
+// crawlScope decides which URLs a crawl may follow. Host and domain scope
+// compare against the seed the crawl started from, so a seed that
+// redirects off-site doesn't carry the crawl along with it.
+type crawlScope struct {
+	scope  string
+	root   *url.URL
+	prefix *regexp.Regexp
+}
+
+func newCrawlScope(opts *models.CrawlOptions, root string) (*crawlScope, error) {
+	s := &crawlScope{scope: opts.Scope}
+	var err error
+	if s.root, err = url.Parse(root); err != nil {
+		return nil, err
+	}
+	if opts.Scope == "prefix" {
+		if s.prefix, err = regexp.Compile(opts.Prefix); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// contains reports whether u is in scope.
+func (s *crawlScope) contains(u *url.URL) bool {
+	switch s.scope {
+	case "domain":
+		a, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(s.root.Hostname()))
+		if err != nil {
+			return sameHost(s.root, u)
+		}
+		b, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(u.Hostname()))
+		return err == nil && a == b
+	case "prefix":
+		return s.prefix.MatchString(u.String())
+	default:
+		return sameHost(s.root, u)
+	}
+}
+
+// enqueueChildren turns the in-scope links of a crawled page into jobs one
+// level deeper. The frontier drops URLs the crawl has already seen and stops
+// the crawl growing past its page budget. nofollow links are not followed.
+func (p *Pool) enqueueChildren(ctx context.Context, job models.Job, page *Page, links []models.Link) {
+	opts := page.Settings.Crawl
+	if opts == nil || job.Depth >= opts.MaxDepth {
+		return
+	}
+
+	root := job.CrawlRoot
+	if root == "" {
+		root = job.URL
+	}
+	scope, err := newCrawlScope(opts, root)
+	if err != nil {
+		fmt.Printf("[Worker] Job %d has an invalid crawl scope: %v\n", job.ID, err)
+		return
+	}
+	// A page that redirected out of scope is recorded but not expanded
+	if !scope.contains(page.URL) {
+		fmt.Printf("[Worker] Job %d landed outside the crawl scope at %s, not following its links\n", job.ID, page.URL)
+		return
+	}
+
+	var children []models.Job
+	for _, link := range links {
+		if slices.Contains(link.Rel, "nofollow") {
+			continue
+		}
+		u, err := url.Parse(link.URL)
+		if err != nil || !scope.contains(u) {
+			continue
+		}
+		children = append(children, models.Job{
+			URL:       link.URL,
+			UserID:    job.UserID,
+			BatchID:   job.BatchID,
+			ParentID:  job.ID,
+			Depth:     job.Depth + 1,
+			CrawlRoot: root,
+			Status:    "pending",
+			FilePath:  job.FilePath,
+			JobType:   job.JobType,
+		})
+	}
+
+	created, err := database.EnqueueCrawlJobs(ctx, p.DB, job.BatchID, children, opts.MaxPages)
+	if err != nil {
+		fmt.Printf("[Worker] Job %d failed to enqueue crawl links: %v\n", job.ID, err)
+		return
+	}
+	if len(created) > 0 {
+		fmt.Printf("[Worker] Job %d discovered %d new pages at depth %d\n", job.ID, len(created), job.Depth+1)
+		p.Wake()
+	}
+}
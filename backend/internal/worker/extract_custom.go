@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sentinel/internal/models"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// customExtractor applies the batch's user-defined selector rules. Single
+// rules yield a string (or null when nothing matched), multiple rules a list.
+type customExtractor struct{}
+
+func init() {
+	Register(customExtractor{})
+}
+
+func (customExtractor) Name() string { return "custom" }
+
+func (customExtractor) Extract(page *Page) (any, error) {
+	out := make(map[string]any, len(page.Settings.Rules))
+	for _, rule := range page.Settings.Rules {
+		values, err := applyRule(page, rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		switch {
+		case rule.Multiple:
+			out[rule.Name] = values
+		case len(values) > 0:
+			out[rule.Name] = values[0]
+		default:
+			out[rule.Name] = nil
+		}
+	}
+	return out, nil
+}
+
+func applyRule(page *Page, rule models.ExtractionRule) ([]string, error) {
+	values := []string{}
+
+	if rule.Type == "xpath" {
+		expr, err := xpath.Compile(rule.Selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, root := range page.Doc.Nodes {
+			// count(), string() and the like yield one value, not nodes
+			result := expr.Evaluate(htmlquery.CreateXPathNavigator(root))
+			if _, ok := result.(*xpath.NodeIterator); !ok {
+				return []string{xpathScalar(result)}, nil
+			}
+			for _, n := range htmlquery.QuerySelectorAll(root, expr) {
+				if v, ok := nodeValue(n, rule); ok {
+					values = append(values, v)
+				}
+				if !rule.Multiple && len(values) > 0 {
+					return values, nil
+				}
+			}
+		}
+		return values, nil
+	}
+
+	sel, err := cascadia.Compile(rule.Selector)
+	if err != nil {
+		return nil, err
+	}
+	page.Doc.FindMatcher(sel).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if v, ok := nodeValue(s.Get(0), rule); ok {
+			values = append(values, v)
+		}
+		return rule.Multiple || len(values) == 0
+	})
+	return values, nil
+}
+
+// xpathScalar formats the number, string or boolean an XPath expression
+// evaluated to.
+func xpathScalar(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// xpathSelectsNodes reports whether expr evaluates to a node-set rather
+// than a number, string or boolean.
+func xpathSelectsNodes(expr *xpath.Expr) bool {
+	doc := &html.Node{Type: html.DocumentNode}
+	_, ok := expr.Evaluate(htmlquery.CreateXPathNavigator(doc)).(*xpath.NodeIterator)
+	return ok
+}
+
+// nodeValue reads a matched node according to the rule's mode. XPath may
+// select attribute or text nodes directly; those yield their own content.
+func nodeValue(n *html.Node, rule models.ExtractionRule) (string, bool) {
+	if rule.Mode == "attr" && n.Type == html.ElementNode {
+		for _, a := range n.Attr {
+			if a.Key == rule.Attr {
+				return a.Val, true
+			}
+		}
+		return "", false
+	}
+	return strings.TrimSpace(htmlquery.InnerText(n)), true
+}
+
+// ValidateRules checks user-supplied rules before a batch is created, so a
+// typo fails the upload rather than every job.
+func ValidateRules(rules []models.ExtractionRule) error {
+	seen := make(map[string]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("rule %q: duplicate name", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if rule.Type == "" {
+			rule.Type = "css"
+		}
+		if rule.Mode == "" {
+			rule.Mode = "text"
+		}
+
+		switch rule.Type {
+		case "css":
+			if _, err := cascadia.Compile(rule.Selector); err != nil {
+				return fmt.Errorf("rule %q: invalid CSS selector: %w", rule.Name, err)
+			}
+		case "xpath":
+			expr, err := xpath.Compile(rule.Selector)
+			if err != nil {
+				return fmt.Errorf("rule %q: invalid XPath: %w", rule.Name, err)
+			}
+			if rule.Mode == "attr" && !xpathSelectsNodes(expr) {
+				return fmt.Errorf("rule %q: attr mode needs an XPath that selects elements", rule.Name)
+			}
+		default:
+			return fmt.Errorf("rule %q: type must be css or xpath", rule.Name)
+		}
+
+		switch rule.Mode {
+		case "text":
+		case "attr":
+			if rule.Attr == "" {
+				return fmt.Errorf("rule %q: attr mode needs an attr", rule.Name)
+			}
+		default:
+			return fmt.Errorf("rule %q: mode must be text or attr", rule.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"sentinel/internal/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// linksExtractor collects every followable link on the page, resolved
+// against the page's base URL and de-duplicated.
+type linksExtractor struct{}
+
+func init() {
+	Register(linksExtractor{})
+}
+
+func (linksExtractor) Name() string { return "links" }
+
+func (linksExtractor) Extract(page *Page) (any, error) {
+	base, err := url.Parse(baseURL(page))
+	if err != nil {
+		base = page.URL
+	}
+
+	links := []models.Link{}
+	seen := make(map[string]int)
+	page.Doc.Find("a[href], area[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		u := normalizeLink(base, href)
+		if u == nil {
+			return
+		}
+		key := u.String()
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		if text == "" {
+			text = strings.TrimSpace(s.AttrOr("alt", s.AttrOr("title", "")))
+		}
+		rel := strings.Fields(strings.ToLower(s.AttrOr("rel", "")))
+
+		// Keep the first occurrence, filling in whatever it was missing
+		if idx, ok := seen[key]; ok {
+			if links[idx].Text == "" {
+				links[idx].Text = text
+			}
+			for _, r := range rel {
+				if !slices.Contains(links[idx].Rel, r) {
+					links[idx].Rel = append(links[idx].Rel, r)
+				}
+			}
+			return
+		}
+		seen[key] = len(links)
+		links = append(links, models.Link{
+			URL:      key,
+			Text:     text,
+			Rel:      rel,
+			Internal: sameHost(u, page.URL),
+		})
+	})
+	return links, nil
+}
+
+// NormalizeURL puts an absolute URL in the form discovered links take, so a
+// crawl seed and a link back to it land on the same frontier entry.
+func NormalizeURL(rawURL string) string {
+	if u := normalizeLink(&url.URL{}, rawURL); u != nil {
+		return u.String()
+	}
+	return rawURL
+}
+
+// normalizeLink resolves href against base and strips the fragment. Links
+// that don't lead to a fetchable page (mailto:, javascript:, bare #anchors,
+// ...) yield nil.
+func normalizeLink(base *url.URL, href string) *url.URL {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return nil
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return nil
+	}
+	u := base.ResolveReference(ref)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u
+}
+
+// sameHost compares two URLs' hosts, treating www.example.com and
+// example.com as the same site.
+func sameHost(a, b *url.URL) bool {
+	trim := func(u *url.URL) string {
+		return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	}
+	return trim(a) == trim(b)
+}
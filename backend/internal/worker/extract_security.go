@@ -0,0 +1,392 @@
+package worker
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sentinel/internal/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// securityExtractor audits the security headers and cookies of a response
+// and grades the page.
+type securityExtractor struct{}
+
+func init() {
+	Register(securityExtractor{})
+}
+
+func (securityExtractor) Name() string { return "security" }
+
+// Score deductions per failed check. A page starts at 100 and the score
+// never goes below 0; each cookie penalty counts once however many cookies
+// fail it.
+const (
+	penaltyNoHTTPS        = 40
+	penaltyHSTS           = 20 // missing, invalid or disabled
+	penaltyHSTSShort      = 10 // max-age under six months
+	penaltyCSP            = 25 // missing or report-only
+	penaltyUnsafeInline   = 15 // also charged when scripts aren't restricted at all
+	penaltyUnsafeEval     = 10
+	penaltyFraming        = 15
+	penaltyNoSniff        = 10
+	penaltyReferrer       = 5
+	penaltyPermissions    = 5
+	penaltyCookieSecure   = 20
+	penaltyCookieHttpOnly = 10
+	penaltyCookieSameSite = 5
+)
+
+// hstsMinMaxAge is the shortest HSTS max-age that passes: six months.
+const hstsMinMaxAge = 180 * 24 * 60 * 60
+
+func (securityExtractor) Extract(page *Page) (any, error) {
+	header := page.Response.Header
+	audit := models.SecurityAudit{HTTPS: page.URL.Scheme == "https"}
+	score := 100
+	if !audit.HTTPS {
+		score -= penaltyNoHTTPS
+	}
+
+	var penalty int
+	audit.HSTS, penalty = checkHSTS(header.Get("Strict-Transport-Security"), audit.HTTPS)
+	score -= penalty
+
+	headerPolicies := header.Values("Content-Security-Policy")
+	audit.CSP, penalty = checkCSP(headerPolicies, metaCSP(page.Doc), header.Values("Content-Security-Policy-Report-Only"))
+	score -= penalty
+
+	frameAncestors := slices.ContainsFunc(headerPolicies, func(p string) bool {
+		_, ok := parseCSP(p)["frame-ancestors"]
+		return ok
+	})
+	audit.XFrameOptions = checkFrameOptions(header.Get("X-Frame-Options"), frameAncestors)
+	audit.XContentTypeOptions = checkNoSniff(header.Get("X-Content-Type-Options"))
+	audit.ReferrerPolicy = checkReferrerPolicy(header.Get("Referrer-Policy"), page.Doc.Find("meta[name='referrer']").AttrOr("content", ""))
+	audit.PermissionsPolicy = checkPermissionsPolicy(header.Get("Permissions-Policy"), header.Get("Feature-Policy"))
+	if !audit.XFrameOptions.Pass {
+		score -= penaltyFraming
+	}
+	if !audit.XContentTypeOptions.Pass {
+		score -= penaltyNoSniff
+	}
+	if !audit.ReferrerPolicy.Pass {
+		score -= penaltyReferrer
+	}
+	if !audit.PermissionsPolicy.Pass {
+		score -= penaltyPermissions
+	}
+
+	audit.Cookies, penalty = checkCookies(page.Response.Cookies())
+	score -= penalty
+
+	audit.Score = max(score, 0)
+	audit.Grade = securityGrade(audit.Score)
+	return audit, nil
+}
+
+func securityGrade(score int) string {
+	switch {
+	case score >= 100:
+		return "A+"
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 70:
+		return "C"
+	case score >= 60:
+		return "D"
+	}
+	return "F"
+}
+
+// checkHSTS parses Strict-Transport-Security, e.g.
+// "max-age=31536000; includeSubDomains; preload". Browsers ignore it on
+// plain HTTP, where the missing HTTPS is already charged.
+func checkHSTS(value string, https bool) (models.HSTSCheck, int) {
+	check := models.HSTSCheck{HeaderCheck: models.HeaderCheck{Value: value}}
+	if !https {
+		check.Issues = append(check.Issues, "page not served over HTTPS")
+		return check, 0
+	}
+	if value == "" {
+		check.Issues = append(check.Issues, "header missing")
+		return check, penaltyHSTS
+	}
+
+	maxAge := -1
+	for _, directive := range strings.Split(value, ";") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "max-age":
+			if n, err := strconv.Atoi(strings.Trim(arg, `"`)); err == nil {
+				maxAge = n
+			}
+		case "includesubdomains":
+			check.IncludeSubDomains = true
+		case "preload":
+			check.Preload = true
+		}
+	}
+
+	switch {
+	case maxAge < 0:
+		check.Issues = append(check.Issues, "max-age missing or invalid")
+		return check, penaltyHSTS
+	case maxAge == 0:
+		check.Issues = append(check.Issues, "max-age=0 disables HSTS")
+		return check, penaltyHSTS
+	}
+	check.MaxAge = maxAge
+	if maxAge < hstsMinMaxAge {
+		check.Issues = append(check.Issues, "max-age under six months")
+		return check, penaltyHSTSShort
+	}
+	check.Pass = true
+	return check, 0
+}
+
+// parseCSP splits one policy into directives and their source lists.
+// Directive names are case-insensitive; only the first of a repeated one
+// counts, as in browsers.
+func parseCSP(policy string) map[string][]string {
+	directives := map[string][]string{}
+	for _, directive := range strings.Split(policy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, ok := directives[name]; !ok {
+			directives[name] = fields[1:]
+		}
+	}
+	return directives
+}
+
+// metaCSP returns the policies a page declares in <meta http-equiv>.
+func metaCSP(doc *goquery.Document) []string {
+	var policies []string
+	doc.Find("meta[http-equiv]").Each(func(i int, s *goquery.Selection) {
+		if strings.EqualFold(strings.TrimSpace(s.AttrOr("http-equiv", "")), "Content-Security-Policy") {
+			policies = append(policies, s.AttrOr("content", ""))
+		}
+	})
+	return policies
+}
+
+// checkCSP evaluates every enforced policy together. A header may carry
+// several comma-separated policies and all of them apply, so scripts are
+// only as open as the strictest policy: inline scripts count as allowed
+// when every policy that restricts scripts allows them.
+func checkCSP(headers, meta, reportOnly []string) (models.CSPCheck, int) {
+	enforced := append(slices.Clone(headers), meta...)
+	var policies []map[string][]string
+	for _, value := range enforced {
+		for _, policy := range strings.Split(value, ",") {
+			if strings.TrimSpace(policy) != "" {
+				policies = append(policies, parseCSP(policy))
+			}
+		}
+	}
+
+	check := models.CSPCheck{HeaderCheck: models.HeaderCheck{Value: strings.Join(enforced, ", ")}}
+	if len(policies) == 0 {
+		check.ReportOnly = len(reportOnly) > 0
+		check.Value = strings.Join(reportOnly, ", ")
+		if check.ReportOnly {
+			check.Issues = append(check.Issues, "only Content-Security-Policy-Report-Only is sent, nothing is enforced")
+		} else {
+			check.Issues = append(check.Issues, "header missing")
+		}
+		return check, penaltyCSP
+	}
+
+	check.Directives = map[string][]string{}
+	restricting, inline, eval := 0, 0, 0
+	for _, policy := range policies {
+		for name, sources := range policy {
+			check.Directives[name] = append(check.Directives[name], sources...)
+		}
+		sources, ok := policy["script-src"]
+		if !ok {
+			sources, ok = policy["default-src"]
+		}
+		if !ok {
+			continue
+		}
+		restricting++
+		if allowsInlineScript(sources) {
+			inline++
+		}
+		if containsSource(sources, "'unsafe-eval'") {
+			eval++
+		}
+	}
+
+	penalty := 0
+	switch {
+	case restricting == 0:
+		check.Issues = append(check.Issues, "no script-src or default-src, scripts are unrestricted")
+		penalty += penaltyUnsafeInline
+	case inline == restricting:
+		check.UnsafeInline = true
+		check.Issues = append(check.Issues, "scripts allow 'unsafe-inline'")
+		penalty += penaltyUnsafeInline
+	}
+	if restricting > 0 && eval == restricting {
+		check.UnsafeEval = true
+		check.Issues = append(check.Issues, "scripts allow 'unsafe-eval'")
+		penalty += penaltyUnsafeEval
+	}
+	check.Pass = penalty == 0
+	return check, penalty
+}
+
+// allowsInlineScript reports whether a source list runs inline scripts.
+// Browsers ignore 'unsafe-inline' next to a nonce, a hash or
+// 'strict-dynamic'.
+func allowsInlineScript(sources []string) bool {
+	if !containsSource(sources, "'unsafe-inline'") {
+		return false
+	}
+	for _, s := range sources {
+		s = strings.ToLower(s)
+		if strings.HasPrefix(s, "'nonce-") || strings.HasPrefix(s, "'sha256-") ||
+			strings.HasPrefix(s, "'sha384-") || strings.HasPrefix(s, "'sha512-") || s == "'strict-dynamic'" {
+			return false
+		}
+	}
+	return true
+}
+
+func containsSource(sources []string, want string) bool {
+	return slices.ContainsFunc(sources, func(s string) bool { return strings.EqualFold(s, want) })
+}
+
+// checkFrameOptions passes DENY or SAMEORIGIN, or a CSP frame-ancestors
+// directive, which supersedes the header.
+func checkFrameOptions(value string, frameAncestors bool) models.HeaderCheck {
+	check := models.HeaderCheck{Value: value}
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "DENY", "SAMEORIGIN":
+		check.Pass = true
+	case "":
+		if frameAncestors {
+			check.Pass = true
+		} else {
+			check.Issues = append(check.Issues, "header missing and no CSP frame-ancestors")
+		}
+	default:
+		if frameAncestors {
+			check.Pass = true
+		} else {
+			check.Issues = append(check.Issues, "value should be DENY or SAMEORIGIN")
+		}
+	}
+	return check
+}
+
+func checkNoSniff(value string) models.HeaderCheck {
+	check := models.HeaderCheck{Value: value}
+	switch {
+	case value == "":
+		check.Issues = append(check.Issues, "header missing")
+	case !strings.EqualFold(strings.TrimSpace(value), "nosniff"):
+		check.Issues = append(check.Issues, "value should be nosniff")
+	default:
+		check.Pass = true
+	}
+	return check
+}
+
+// Referrer policies that leak full URLs to other origins.
+var leakyReferrerPolicies = []string{"unsafe-url", "no-referrer-when-downgrade"}
+
+// checkReferrerPolicy looks at the header, falling back to
+// <meta name="referrer">. The header may list fallbacks; the last one the
+// browser knows wins, so the last token is taken.
+func checkReferrerPolicy(value, meta string) models.HeaderCheck {
+	if value == "" {
+		value = meta
+	}
+	check := models.HeaderCheck{Value: value}
+	tokens := strings.Split(value, ",")
+	policy := strings.ToLower(strings.TrimSpace(tokens[len(tokens)-1]))
+	switch {
+	case policy == "":
+		check.Issues = append(check.Issues, "header missing")
+	case slices.Contains(leakyReferrerPolicies, policy):
+		check.Issues = append(check.Issues, policy+" sends full URLs to other origins")
+	default:
+		check.Pass = true
+	}
+	return check
+}
+
+func checkPermissionsPolicy(value, featurePolicy string) models.HeaderCheck {
+	check := models.HeaderCheck{Value: value}
+	switch {
+	case value != "":
+		check.Pass = true
+	case featurePolicy != "":
+		check.Value = featurePolicy
+		check.Issues = append(check.Issues, "only the deprecated Feature-Policy header is sent")
+	default:
+		check.Issues = append(check.Issues, "header missing")
+	}
+	return check
+}
+
+// checkCookies flags cookies without Secure, HttpOnly or SameSite, and
+// SameSite=None without Secure, which browsers reject.
+func checkCookies(cookies []*http.Cookie) ([]models.CookieCheck, int) {
+	checks := []models.CookieCheck{}
+	var noSecure, noHTTPOnly, noSameSite bool
+	for _, c := range cookies {
+		check := models.CookieCheck{Name: c.Name, Secure: c.Secure, HttpOnly: c.HttpOnly}
+		switch c.SameSite {
+		case http.SameSiteStrictMode:
+			check.SameSite = "Strict"
+		case http.SameSiteLaxMode:
+			check.SameSite = "Lax"
+		case http.SameSiteNoneMode:
+			check.SameSite = "None"
+		}
+
+		if !c.Secure {
+			check.Issues = append(check.Issues, "missing Secure")
+			noSecure = true
+		}
+		if !c.HttpOnly {
+			check.Issues = append(check.Issues, "missing HttpOnly")
+			noHTTPOnly = true
+		}
+		switch {
+		case check.SameSite == "":
+			check.Issues = append(check.Issues, "missing SameSite")
+			noSameSite = true
+		case check.SameSite == "None" && !c.Secure:
+			check.Issues = append(check.Issues, "SameSite=None without Secure")
+			noSameSite = true
+		}
+		check.Pass = len(check.Issues) == 0
+		checks = append(checks, check)
+	}
+
+	penalty := 0
+	if noSecure {
+		penalty += penaltyCookieSecure
+	}
+	if noHTTPOnly {
+		penalty += penaltyCookieHttpOnly
+	}
+	if noSameSite {
+		penalty += penaltyCookieSameSite
+	}
+	return checks, penalty
+}
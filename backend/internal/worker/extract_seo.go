@@ -0,0 +1,28 @@
+package worker
+
+import "strings"
+
+// seoExtractor captures the basic on-page SEO fields.
+type seoExtractor struct{}
+
+type seoData struct {
+	Title           string `json:"title"`
+	H1              string `json:"h1"`
+	MetaDescription string `json:"meta_description"`
+}
+
+func init() {
+	Register(seoExtractor{})
+}
+
+func (seoExtractor) Name() string { return "seo" }
+
+func (seoExtractor) Extract(page *Page) (any, error) {
+	metaDescription, _ := page.Doc.Find("meta[name='description']").Attr("content")
+
+	return seoData{
+		Title:           strings.TrimSpace(page.Doc.Find("title").Text()),
+		H1:              strings.TrimSpace(page.Doc.Find("h1").Text()),
+		MetaDescription: metaDescription,
+	}, nil
+}
@@ -0,0 +1,220 @@
+package worker
+
+import (
+	"encoding/json"
+	"strings"
+
+	"sentinel/internal/models"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// structuredExtractor collects JSON-LD, Microdata, OpenGraph, Twitter Cards,
+// the canonical URL, hreflang alternates and robots directives.
+type structuredExtractor struct{}
+
+func init() {
+	Register(structuredExtractor{})
+}
+
+func (structuredExtractor) Name() string { return "structured" }
+
+func (structuredExtractor) Extract(page *Page) (any, error) {
+	base := baseURL(page)
+	data := models.StructuredData{
+		OpenGraph: map[string][]string{},
+		Twitter:   map[string]string{},
+	}
+
+	if href, ok := page.Doc.Find("link[rel~='canonical']").First().Attr("href"); ok {
+		data.Canonical = resolveURL(base, href)
+	}
+
+	page.Doc.Find("link[rel~='alternate'][hreflang]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		lang, _ := s.Attr("hreflang")
+		data.Hreflang = append(data.Hreflang, models.HreflangLink{
+			Lang: strings.ToLower(strings.TrimSpace(lang)),
+			URL:  resolveURL(base, href),
+		})
+	})
+
+	page.Doc.Find("meta").Each(func(i int, s *goquery.Selection) {
+		content, ok := s.Attr("content")
+		if !ok {
+			return
+		}
+		// Sites mix up name= and property= for both vocabularies
+		key := s.AttrOr("property", "")
+		if key == "" {
+			key = s.AttrOr("name", "")
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		switch {
+		case strings.HasPrefix(key, "og:"):
+			k := strings.TrimPrefix(key, "og:")
+			data.OpenGraph[k] = append(data.OpenGraph[k], content)
+		case strings.HasPrefix(key, "twitter:"):
+			data.Twitter[strings.TrimPrefix(key, "twitter:")] = content
+		case key == "robots" || strings.HasSuffix(key, "bot"):
+			addRobotsDirectives(&data.Robots, key, content)
+		}
+	})
+	for _, v := range page.Response.Header.Values("X-Robots-Tag") {
+		addRobotsDirectives(&data.Robots, "", v)
+	}
+
+	page.Doc.Find("script[type='application/ld+json']").Each(func(i int, s *goquery.Selection) {
+		data.JSONLD = append(data.JSONLD, parseJSONLD(s.Text())...)
+	})
+
+	page.Doc.Find("[itemscope]:not([itemprop])").Each(func(i int, s *goquery.Selection) {
+		data.Microdata = append(data.Microdata, parseMicrodataItem(s.Get(0), base))
+	})
+
+	return data, nil
+}
+
+// addRobotsDirectives records comma-separated directives for a meta tag
+// (name) or the X-Robots-Tag header (empty name). Header values may be
+// scoped to a bot as "googlebot: noindex".
+func addRobotsDirectives(r *models.RobotsDirectives, name, content string) {
+	if name == "" {
+		if agent, rest, ok := strings.Cut(content, ":"); ok && !strings.ContainsAny(agent, ", ") {
+			content = rest
+			name = strings.ToLower(strings.TrimSpace(agent))
+		}
+	}
+
+	var directives []string
+	for _, d := range strings.Split(content, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" {
+			continue
+		}
+		directives = append(directives, d)
+		if d == "noindex" || d == "none" {
+			r.NoIndex = true
+		}
+		if d == "nofollow" || d == "none" {
+			r.NoFollow = true
+		}
+	}
+
+	if name == "" {
+		r.Header = append(r.Header, directives...)
+		return
+	}
+	if r.Meta == nil {
+		r.Meta = map[string][]string{}
+	}
+	r.Meta[name] = append(r.Meta[name], directives...)
+}
+
+// parseJSONLD decodes one JSON-LD block into a flat list of nodes: top-level
+// arrays and @graph containers are unwrapped. Malformed blocks are skipped.
+func parseJSONLD(raw string) []map[string]any {
+	var v any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &v); err != nil {
+		return nil
+	}
+
+	var out []map[string]any
+	var walk func(v any, context any)
+	walk = func(v any, context any) {
+		switch t := v.(type) {
+		case []any:
+			for _, item := range t {
+				walk(item, context)
+			}
+		case map[string]any:
+			if ctx, ok := t["@context"]; ok {
+				context = ctx
+			}
+			if graph, ok := t["@graph"]; ok {
+				walk(graph, context)
+				return
+			}
+			if _, ok := t["@context"]; !ok && context != nil {
+				t["@context"] = context
+			}
+			out = append(out, t)
+		}
+	}
+	walk(v, nil)
+	return out
+}
+
+func parseMicrodataItem(n *html.Node, base string) models.MicrodataItem {
+	item := models.MicrodataItem{Properties: map[string][]any{}}
+	if t := attr(n, "itemtype"); t != "" {
+		item.Type = strings.Fields(t)
+	}
+	item.ID = attr(n, "itemid")
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			_, scoped := attrLookup(c, "itemscope")
+			props := strings.Fields(attr(c, "itemprop"))
+
+			if len(props) > 0 {
+				var value any
+				if scoped {
+					value = parseMicrodataItem(c, base)
+				} else {
+					value = microdataValue(c, base)
+				}
+				for _, p := range props {
+					item.Properties[p] = append(item.Properties[p], value)
+				}
+			}
+			// A nested itemscope owns everything beneath it
+			if !scoped {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return item
+}
+
+// microdataValue follows the HTML spec's rules for an itemprop's value.
+func microdataValue(n *html.Node, base string) string {
+	switch n.Data {
+	case "meta":
+		return attr(n, "content")
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		return resolveURL(base, attr(n, "src"))
+	case "a", "area", "link":
+		return resolveURL(base, attr(n, "href"))
+	case "object":
+		return resolveURL(base, attr(n, "data"))
+	case "data", "meter":
+		return attr(n, "value")
+	case "time":
+		if v, ok := attrLookup(n, "datetime"); ok {
+			return v
+		}
+	}
+	return strings.TrimSpace(goquery.NewDocumentFromNode(n).Text())
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := attrLookup(n, key)
+	return v
+}
+
+func attrLookup(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"sentinel/internal/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Page is what extractors see: the fetched response and its parsed document.
+// The response body has already been read into Body.
+type Page struct {
+	URL      *url.URL
+	Response *http.Response
+	Body     []byte
+	Doc      *goquery.Document
+	Settings *models.BatchSettings
+}
+
+// baseURL is what relative URLs on the page resolve against: the <base href>
+// if the page declares one, the page URL otherwise.
+func baseURL(page *Page) string {
+	if href, ok := page.Doc.Find("base[href]").First().Attr("href"); ok {
+		return resolveURL(page.URL.String(), href)
+	}
+	return page.URL.String()
+}
+
+// resolveURL resolves ref against base, returning ref untouched if either
+// doesn't parse.
+func resolveURL(base, ref string) string {
+	ref = strings.TrimSpace(ref)
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+// Extractor pulls one kind of data out of a page. Its output is stored under
+// its Name in the result's "extracted" section.
+type Extractor interface {
+	Name() string
+	Extract(page *Page) (any, error)
+}
+
+// DefaultExtractors run for batches that don't pick their own.
+var DefaultExtractors = []string{"seo", "links", "structured", "security"}
+
+var registry = map[string]Extractor{}
+
+// Register makes an extractor available to batches. Built-ins register
+// themselves from init; it is not safe to call once the pool is running.
+func Register(e Extractor) {
+	registry[e.Name()] = e
+}
+
+// ExtractorNames lists every registered extractor, sorted.
+func ExtractorNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateExtractors checks that every name refers to a registered extractor.
+func ValidateExtractors(names []string) error {
+	for _, name := range names {
+		if _, ok := registry[name]; !ok {
+			return fmt.Errorf("unknown extractor %q (available: %v)", name, ExtractorNames())
+		}
+	}
+	return nil
+}
+
+// runExtractors applies the batch's extractors to a page. A failing extractor
+// doesn't fail the job; its error is reported next to the other sections.
+func runExtractors(page *Page, names []string) (map[string]any, map[string]string) {
+	if len(names) == 0 {
+		names = DefaultExtractors
+	}
+
+	extracted := make(map[string]any, len(names))
+	var errs map[string]string
+	for _, name := range names {
+		e, ok := registry[name]
+		if !ok {
+			continue
+		}
+		out, err := e.Extract(page)
+		if err != nil {
+			if errs == nil {
+				errs = make(map[string]string)
+			}
+			errs[name] = err.Error()
+			continue
+		}
+		extracted[name] = out
+	}
+	return extracted, errs
+}
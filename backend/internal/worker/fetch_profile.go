@@ -0,0 +1,201 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"sentinel/internal/fetcher"
+	"sentinel/internal/models"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Fetch defaults, used for anything a batch's profile leaves unset.
+const (
+	DefaultFetchTimeout = 10 * time.Second
+	MaxFetchTimeout     = 2 * time.Minute
+	DefaultMaxRedirects = 10
+	MaxRedirectLimit    = 30
+	// Redirect chains longer than this are flagged in the results
+	DefaultLongRedirectChain = 3
+	maxFetchBody             = 1 << 20
+	maxPageBody              = 2 << 20 // bytes of a page read for extraction
+)
+
+// Headers the transport manages itself; a profile may not override them.
+var reservedHeaders = []string{"Host", "Content-Length", "Connection", "Transfer-Encoding", "Te", "Upgrade", "Trailer"}
+
+// ValidateFetchProfile normalizes a batch's fetch profile and rejects
+// requests we won't send.
+func ValidateFetchProfile(fp *models.FetchProfile) error {
+	fp.Method = strings.ToUpper(strings.TrimSpace(fp.Method))
+	switch fp.Method {
+	case "":
+		fp.Method = http.MethodGet
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("fetch method %q not supported (want GET, POST, PUT or PATCH)", fp.Method)
+	}
+	if fp.Body != "" && fp.Method == http.MethodGet {
+		return fmt.Errorf("fetch body needs a POST, PUT or PATCH method")
+	}
+	if len(fp.Body) > maxFetchBody {
+		return fmt.Errorf("fetch body is larger than %d bytes", maxFetchBody)
+	}
+
+	if fp.TimeoutMS < 0 || time.Duration(fp.TimeoutMS)*time.Millisecond > MaxFetchTimeout {
+		return fmt.Errorf("fetch timeout_ms must be between 1 and %d", MaxFetchTimeout.Milliseconds())
+	}
+	if fp.MaxRedirects != nil && (*fp.MaxRedirects < 0 || *fp.MaxRedirects > MaxRedirectLimit) {
+		return fmt.Errorf("fetch max_redirects must be between 0 and %d", MaxRedirectLimit)
+	}
+	if fp.LongRedirectChain < 0 || fp.LongRedirectChain > MaxRedirectLimit {
+		return fmt.Errorf("fetch long_redirect_chain must be between 1 and %d", MaxRedirectLimit)
+	}
+
+	for name, value := range fp.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("invalid fetch header %q", name)
+		}
+		if slices.Contains(reservedHeaders, http.CanonicalHeaderKey(name)) {
+			return fmt.Errorf("fetch header %q can't be overridden", name)
+		}
+		// The agent also picks the robots.txt group, so it lives in one place
+		if http.CanonicalHeaderKey(name) == "User-Agent" {
+			if fp.UserAgent != "" && fp.UserAgent != value {
+				return fmt.Errorf("fetch header %q conflicts with user_agent", name)
+			}
+			fp.UserAgent = value
+			delete(fp.Headers, name)
+		}
+	}
+	if !httpguts.ValidHeaderFieldValue(fp.UserAgent) {
+		return fmt.Errorf("invalid fetch user_agent")
+	}
+	for name, value := range fp.Cookies {
+		if name == "" || !httpguts.ValidHeaderFieldValue(name+"="+value) || strings.ContainsAny(name+value, ";\r\n") {
+			return fmt.Errorf("invalid fetch cookie %q", name)
+		}
+	}
+
+	for i, t := range fp.AcceptTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if _, _, err := mime.ParseMediaType(t); err != nil && t != "*/*" {
+			return fmt.Errorf("invalid accept type %q", t)
+		}
+		fp.AcceptTypes[i] = t
+	}
+	return nil
+}
+
+// fetchPolicy turns a batch's profile into the limits of one fetch.
+func fetchPolicy(fp *models.FetchProfile) fetcher.Policy {
+	policy := fetcher.Policy{
+		Timeout:      DefaultFetchTimeout,
+		MaxRedirects: DefaultMaxRedirects,
+		MaxBody:      maxPageBody,
+	}
+	if fp != nil && fp.TimeoutMS > 0 {
+		policy.Timeout = time.Duration(fp.TimeoutMS) * time.Millisecond
+	}
+	if fp != nil && fp.MaxRedirects != nil {
+		policy.MaxRedirects = *fp.MaxRedirects
+	}
+	return policy
+}
+
+// redirectChain describes how a fetch got to its final response, or
+// returns nil if it didn't redirect.
+func redirectChain(result *fetcher.Result, fp *models.FetchProfile) *models.RedirectChain {
+	if len(result.Redirects) == 0 {
+		return nil
+	}
+	longChain := DefaultLongRedirectChain
+	if fp != nil && fp.LongRedirectChain > 0 {
+		longChain = fp.LongRedirectChain
+	}
+	return &models.RedirectChain{
+		Hops:      result.Redirects,
+		Loop:      result.Loop,
+		TooLong:   len(result.Redirects) > longChain,
+		Truncated: result.Truncated,
+	}
+}
+
+// newRequest builds the request for one job from its batch's profile.
+func (p *Pool) newRequest(ctx context.Context, rawURL string, fp *models.FetchProfile) (*http.Request, error) {
+	if fp == nil {
+		fp = &models.FetchProfile{}
+	}
+	method := fp.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if fp.Body != "" {
+		body = strings.NewReader(fp.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", p.userAgent(fp))
+	if len(fp.AcceptTypes) > 0 {
+		req.Header.Set("Accept", strings.Join(fp.AcceptTypes, ", "))
+	}
+	for name, value := range fp.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range fp.Cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	return req, nil
+}
+
+// userAgent is the UA a batch fetches with, which is also the one its
+// robots.txt rules are matched against.
+func (p *Pool) userAgent(fp *models.FetchProfile) string {
+	if fp != nil && fp.UserAgent != "" {
+		return fp.UserAgent
+	}
+	return p.UserAgent
+}
+
+// ContentTypeError is returned for responses whose media type the batch's
+// profile doesn't accept. It is never retried.
+type ContentTypeError struct {
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("content type %q not accepted", e.ContentType)
+}
+
+// acceptsContentType matches a response's Content-Type against the
+// profile's accept list, which may use type/* wildcards.
+func acceptsContentType(fp *models.FetchProfile, contentType string) bool {
+	if fp == nil || len(fp.AcceptTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	for _, want := range fp.AcceptTypes {
+		switch {
+		case want == "*/*" || want == mediaType:
+			return true
+		case strings.HasSuffix(want, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(want, "*")):
+			return true
+		}
+	}
+	return false
+}
@@ -6,36 +6,118 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"math/rand/v2"
+	"net/url"
+	"os"
 	"sync"
 	"time"
 
 	"sentinel/internal/database"
+	"sentinel/internal/events"
+	"sentinel/internal/fetcher"
 	"sentinel/internal/models"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const DefaultUserAgent = "SentinelBot/1.0 (+https://github.com/ravdreamin/Sentinel)"
+
 type Pool struct {
 	DB          *pgxpool.Pool
 	Concurrency int
-	JobChan     chan models.Job
 	Wg          sync.WaitGroup
+
+	// Politeness settings, applied per host by the scheduler
+	PerHostLimit int
+	PerHostDelay time.Duration
+
+	// UserAgent is sent with every fetch and matched against robots.txt groups
+	UserAgent string
+	Robots    *RobotsCache
+
+	// Fetcher holds the transport shared by every fetch the pool makes
+	Fetcher *fetcher.Fetcher
+
+	// RetryPolicies are keyed by job type; DefaultRetryPolicy covers the rest
+	RetryPolicies map[string]RetryPolicy
+
+	// Events receives a message every time a job settles
+	Events events.Broker
+
+	// Queue settings: how many claimed jobs to keep in memory, how long a
+	// claim is held before another worker may take it, and how often to poll
+	QueueSize    int
+	Lease        time.Duration
+	PollInterval time.Duration
+
+	// owner names this pool on the leases it takes
+	owner string
+
+	sched *Scheduler
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+
+	// settings caches batch settings; they don't change once a batch exists
+	settingsMu sync.Mutex
+	settings   map[int]*models.BatchSettings
+
+	// jobCtx is cancelled when a shutdown deadline passes, aborting the
+	// fetches and DB writes of jobs still in flight
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
 }
 
 func New(db *pgxpool.Pool, concurrency int) *Pool {
 
 	return &Pool{
-		DB:          db,
-		Concurrency: concurrency,
-		JobChan:     make(chan models.Job, 10000),
+		DB:           db,
+		Concurrency:  concurrency,
+		PerHostLimit: 2,
+		PerHostDelay: 500 * time.Millisecond,
+		UserAgent:    DefaultUserAgent,
+		Robots:       NewRobotsCache(DefaultUserAgent, time.Hour),
+		Fetcher:      fetcher.New(fetcher.Options{}),
+		RetryPolicies: map[string]RetryPolicy{
+			"web": DefaultRetryPolicy,
+		},
+		Events:       events.NewMemoryBroker(),
+		QueueSize:    concurrency * 2,
+		Lease:        10 * time.Minute,
+		PollInterval: 2 * time.Second,
+		owner:        leaseOwner(),
+		wake:         make(chan struct{}, 1),
+		settings:     make(map[int]*models.BatchSettings),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
 	}
 }
 
+// leaseOwner identifies this process on the leases it holds.
+func leaseOwner() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%08x", hostname, os.Getpid(), rand.Uint32())
+}
+
 func (p *Pool) Run() {
+	p.sched = NewScheduler(p.PerHostLimit, p.PerHostDelay)
+	p.jobCtx, p.cancelJobs = context.WithCancel(context.Background())
+	// robots.txt goes over the same pooled connections as the pages
+	p.Robots.client = p.Fetcher.Client(10*time.Second, DefaultMaxRedirects)
+
+	// Anything a previous process claimed but never finished goes back in line
+	if n, err := database.RecoverJobs(context.Background(), p.DB); err != nil {
+		fmt.Printf("[Queue] Recovery failed: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("[Queue] Recovered %d unfinished jobs\n", n)
+	}
+
+	go p.claimLoop()
+
 	for i := 0; i < p.Concurrency; i++ {
 		p.Wg.Add(1)
 		go p.work(i)
@@ -43,43 +125,198 @@ func (p *Pool) Run() {
 	}
 }
 
+// Wake tells the pool new jobs are waiting so it claims them without waiting
+// for the next poll.
+func (p *Pool) Wake() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// claimLoop keeps the scheduler topped up from the jobs table. Hosts that
+// already have a backlog in memory are skipped so a file dominated by one
+// domain can't hold leases on thousands of rows it won't reach for a while.
+func (p *Pool) claimLoop() {
+	ticker := time.NewTicker(p.PollInterval)
+	defer ticker.Stop()
+	// Jobs can wait in memory longer than a lease behind a slow host, so
+	// leases are renewed well before they run out
+	renew := time.NewTicker(p.Lease / 3)
+	defer renew.Stop()
+	defer close(p.done)
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		if free := p.QueueSize - p.sched.Len(); free > 0 {
+			busy := p.sched.BacklogHosts(p.PerHostLimit * 2)
+			jobs, err := database.ClaimJobs(context.Background(), p.DB, p.owner, free, p.PerHostLimit*2, p.Lease, busy)
+			if err != nil {
+				fmt.Printf("[Queue] Claim failed: %v\n", err)
+			}
+			for _, job := range jobs {
+				p.sched.Push(job)
+			}
+			// A full batch likely means more is waiting
+			if len(jobs) == free {
+				continue
+			}
+		}
+
+		select {
+		case <-p.wake:
+		case <-ticker.C:
+		case <-renew.C:
+			if _, err := database.RenewLeases(context.Background(), p.DB, p.owner, p.Lease); err != nil {
+				fmt.Printf("[Queue] Lease renewal failed: %v\n", err)
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Shutdown stops claiming work and hands jobs that were claimed but never
+// started back to the queue. In-flight jobs get until ctx is done to finish;
+// after that their fetches are cancelled and they are returned to pending.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	close(p.stop)
+	<-p.done
+
+	queued := p.sched.Drain()
+	ids := make([]int, len(queued))
+	for i, job := range queued {
+		ids[i] = job.ID
+	}
+	if _, err := database.ReleaseJobs(context.Background(), p.DB, p.owner, ids, false); err != nil {
+		fmt.Printf("[Queue] Failed to release queued jobs: %v\n", err)
+	}
+
+	// Nothing fetches once the workers are done, so their connections can go
+	finished := make(chan struct{})
+	go func() {
+		p.Wg.Wait()
+		p.Fetcher.CloseIdleConnections()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		p.cancelJobs()
+		<-finished
+		return ctx.Err()
+	}
+}
+
 func (p *Pool) work(workerID int) {
 	defer p.Wg.Done()
 
-	for job := range p.JobChan {
+	for {
+		job, ok := p.sched.Next()
+		if !ok {
+			return
+		}
 		fmt.Printf("[Worker %d] Processing: %s\n", workerID, job.URL)
 
-		p.processJob(job)
+		p.processJob(p.jobCtx, job)
+		p.sched.Done(job)
 	}
 }
 
-func (p *Pool) processJob(job models.Job) {
-	startTime := time.Now()
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+func (p *Pool) processJob(ctx context.Context, job models.Job) {
+	attempts, err := database.StartJobAttempt(ctx, p.DB, p.owner, job.ID, p.Lease)
+	if errors.Is(err, database.ErrLeaseLost) {
+		// Our lease ran out and someone else has the job now
+		fmt.Printf("[Worker] Job %d was claimed elsewhere, skipping\n", job.ID)
+		return
+	}
+	if err != nil {
+		attempts = job.Attempts + 1
 	}
+	job.Attempts = attempts
 
-	// Helper to fail job
+	// Helper to fail job, retrying transient errors per the job type's policy.
+	// A job cut short by shutdown isn't a failure: it goes back in the queue.
 	failJob := func(err error) {
-		fmt.Printf("[Worker] Job %d Failed: %v\n", job.ID, err)
-		database.UpdateJobStatus(p.DB, job.ID, "Failed")
+		if ctx.Err() != nil {
+			p.releaseJob(job)
+			return
+		}
+		p.failJob(ctx, job, err)
+	}
+
+	target, err := url.Parse(job.URL)
+	if err != nil {
+		failJob(err)
+		return
+	}
+
+	settings, err := p.batchSettings(ctx, job.BatchID)
+	if err != nil {
+		// Without its settings the job would run with the wrong profile,
+		// rules and extractors, so try again later instead
+		failJob(err)
+		return
 	}
+	profile := settings.Fetch
 
-	resp, err := client.Get(job.URL)
+	// Respect robots.txt before touching the page, as the agent we fetch as
+	rules := p.Robots.GetFor(target, p.userAgent(profile))
+	if rules.CrawlDelay > 0 {
+		p.sched.SetHostDelay(hostKey(job.URL), rules.CrawlDelay)
+	}
+	if rules.Unavailable {
+		// A server error on robots.txt says nothing lasting about the page;
+		// retry once the cached error has expired
+		failJob(&TransientError{Err: errors.New("robots.txt unavailable"), After: robotsRetryTTL})
+		return
+	}
+	if !rules.Allowed(target) {
+		fmt.Printf("[Worker] Job %d Blocked by robots.txt: %s\n", job.ID, job.URL)
+		database.UpdateJobStatus(ctx, p.DB, job.ID, "Blocked")
+		p.publish(job, "Blocked")
+		return
+	}
+
+	req, err := p.newRequest(ctx, job.URL, profile)
 	if err != nil {
 		failJob(err)
 		return
 	}
-	responseTime := time.Since(startTime).Milliseconds()
-	defer resp.Body.Close()
 
-	limitContent := io.LimitReader(resp.Body, 2<<20)
-	body, err := io.ReadAll(limitContent)
+	result, err := p.Fetcher.Fetch(req, fetchPolicy(profile))
 	if err != nil {
+		// A certificate that failed verification is still worth keeping
+		// for audits, e.g. to list expired ones
+		if finalURL, info := fetcher.RejectedTLSInfo(err); info != nil && ctx.Err() == nil {
+			data := models.CrawlData{URL: job.URL, FinalURL: finalURL, TLS: info, Error: err.Error()}
+			if err := p.storeResult(ctx, job.ID, data); err != nil {
+				fmt.Printf("[Worker] Job %d failed to store certificate details: %v\n", job.ID, err)
+			}
+		}
 		failJob(err)
 		return
 	}
+	resp, body := result.Response, result.Body
+
+	statusErr := &StatusError{Code: resp.StatusCode}
+	if p.retryPolicy(job.JobType).Retryable(statusErr) {
+		failJob(statusErr)
+		return
+	}
+	// A redirect the fetch stopped at is recorded whatever its body is
+	stoppedAtRedirect := len(result.Redirects) > 0 && result.Redirects[len(result.Redirects)-1].URL == resp.Request.URL.String()
+	if contentType := resp.Header.Get("Content-Type"); !stoppedAtRedirect && !acceptsContentType(profile, contentType) {
+		failJob(&ContentTypeError{ContentType: contentType})
+		return
+	}
 
 	hash := sha256.Sum256(body)
 	contentHash := hex.EncodeToString(hash[:])
@@ -89,52 +326,115 @@ func (p *Pool) processJob(job models.Job) {
 		return
 	}
 
-	pageTitle := doc.Find("title").Text()
-	h1 := doc.Find("h1").Text()
-	metaDescription, exists := doc.Find("meta[name='description']").Attr("content")
-	if !exists {
-		metaDescription = ""
+	// Relative links resolve against wherever redirects landed
+	page := &Page{
+		URL:      resp.Request.URL,
+		Response: resp,
+		Body:     body,
+		Doc:      doc,
+		Settings: settings,
 	}
-
-	var links []string
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if exists {
-			links = append(links, href)
-		}
-
-	})
+	extracted, extractErrs := runExtractors(page, settings.Extractors)
 
 	data := models.CrawlData{
-		URL:             job.URL,
-		ResponseTime:    int(responseTime),
-		StatusCode:      resp.StatusCode,
-		ContentHash:     contentHash,
-		Title:           pageTitle,
-		H1:              h1,
-		MetaDescription: metaDescription,
-		Links:           links,
+		URL:           job.URL,
+		FinalURL:      resp.Request.URL.String(),
+		StatusCode:    resp.StatusCode,
+		Protocol:      resp.Proto,
+		Headers:       resp.Header,
+		TLS:           fetcher.TLSInfo(resp.TLS),
+		Redirects:     redirectChain(result, profile),
+		Timings:       result.Timings,
+		ContentHash:   contentHash,
+		Extracted:     extracted,
+		ExtractErrors: extractErrs,
 	}
 
-	dataDb, err := json.Marshal(data)
-	if err != nil {
+	// Always store results (even for guests, so they can download)
+	if err := p.storeResult(ctx, job.ID, data); err != nil {
 		failJob(err)
 		return
 	}
 
-	// Always store results (even for guests, so they can download)
-	// The previous code had `if job.UserID != 0`. We removed it.
-	query := "INSERT INTO results(job_id,data) VALUES ($1,$2)"
-	_, err = p.DB.Exec(context.Background(), query, job.ID, dataDb)
-	if err != nil {
-		failJob(fmt.Errorf("DB result insert failed: %v", err))
-		return
+	// Children are enqueued before the parent settles so the batch never
+	// looks finished while a crawl still has pages to add
+	if job.JobType == "crawl" {
+		links, ok := extracted["links"].([]models.Link)
+		if !ok {
+			found, _ := linksExtractor{}.Extract(page)
+			links = found.([]models.Link)
+		}
+		p.enqueueChildren(ctx, job, page, links)
 	}
 
-	err = database.UpdateJobStatus(p.DB, job.ID, "Completed")
+	err = database.UpdateJobStatus(ctx, p.DB, job.ID, "Completed")
 	if err != nil {
 		fmt.Printf("[Worker] Failed to update job %d to Completed: %v\n", job.ID, err)
 	} else {
-		fmt.Printf("[Worker] Job %d Completed in %dms\n", job.ID, responseTime)
+		fmt.Printf("[Worker] Job %d Completed in %.0fms\n", job.ID, result.Timings.Total)
+		p.publish(job, "Completed")
+	}
+}
+
+// storeResult saves what a job found.
+func (p *Pool) storeResult(ctx context.Context, jobID int, data models.CrawlData) error {
+	dataDb, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO results(job_id,data) VALUES ($1,$2)"
+	if _, err := p.DB.Exec(ctx, query, jobID, dataDb); err != nil {
+		return fmt.Errorf("DB result insert failed: %v", err)
+	}
+	return nil
+}
+
+// batchSettings returns the settings of a job's batch. A failure to load
+// them is transient unless the batch no longer exists.
+func (p *Pool) batchSettings(ctx context.Context, batchID int) (*models.BatchSettings, error) {
+	p.settingsMu.Lock()
+	settings, ok := p.settings[batchID]
+	p.settingsMu.Unlock()
+	if ok {
+		return settings, nil
+	}
+
+	settings, err := database.GetBatchSettings(ctx, p.DB, batchID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("batch %d not found", batchID)
+	}
+	if err != nil {
+		return nil, &TransientError{Err: fmt.Errorf("load batch settings: %w", err)}
+	}
+
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	// Settings are tiny, but don't let a long-running pool grow without bound
+	if len(p.settings) >= 1000 {
+		clear(p.settings)
+	}
+	p.settings[batchID] = settings
+	return settings, nil
+}
+
+// publish tells subscribers of the job's batch where the job ended up.
+func (p *Pool) publish(job models.Job, status string) {
+	p.Events.Publish(events.Event{
+		BatchID: job.BatchID,
+		JobID:   job.ID,
+		URL:     job.URL,
+		Status:  status,
+	})
+}
+
+// releaseJob returns an interrupted job to pending without counting the
+// attempt. It runs on its own short context since the job's is already done.
+func (p *Pool) releaseJob(job models.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fmt.Printf("[Worker] Job %d interrupted by shutdown, returning to queue\n", job.ID)
+	if _, err := database.ReleaseJobs(ctx, p.DB, p.owner, []int{job.ID}, true); err != nil {
+		fmt.Printf("[Worker] Failed to release job %d: %v\n", job.ID, err)
 	}
 }
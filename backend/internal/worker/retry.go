@@ -0,0 +1,133 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"syscall"
+	"time"
+
+	"sentinel/internal/database"
+	"sentinel/internal/models"
+)
+
+// RetryPolicy decides whether a failed job is tried again and how long to
+// wait before the next attempt.
+type RetryPolicy struct {
+	MaxAttempts        int
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	RetryableStatus    []int
+	RetryNetworkErrors bool
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:        3,
+	BaseDelay:          2 * time.Second,
+	MaxDelay:           time.Minute,
+	RetryableStatus:    []int{408, 425, 429, 500, 502, 503, 504},
+	RetryNetworkErrors: true,
+}
+
+// Backoff returns the wait before the given attempt (1-based) is retried:
+// exponential growth capped at MaxDelay, with "equal jitter" so a burst of
+// failures against one host doesn't come back in lockstep.
+func (rp RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := rp.BaseDelay << max(attempt-1, 0)
+	if delay <= 0 || delay > rp.MaxDelay {
+		delay = rp.MaxDelay
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// Retryable reports whether err is worth another attempt under this policy.
+func (rp RetryPolicy) Retryable(err error) bool {
+	var transient *TransientError
+	if errors.As(err, &transient) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(rp.RetryableStatus, statusErr.Code)
+	}
+	return rp.RetryNetworkErrors && isNetworkError(err)
+}
+
+// StatusError is returned for responses whose status code the retry policy
+// treats as a transient failure.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.Code)
+}
+
+// TransientError is a failure on our side, like a database blip, that says
+// nothing about the URL itself. It is always retried, no sooner than After.
+type TransientError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *TransientError) Error() string { return e.Err.Error() }
+
+func (e *TransientError) Unwrap() error { return e.Err }
+
+// isNetworkError matches the transient transport failures we see in the wild:
+// timeouts, DNS hiccups, refused or reset connections and truncated bodies.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+func (p *Pool) retryPolicy(jobType string) RetryPolicy {
+	if rp, ok := p.RetryPolicies[jobType]; ok {
+		return rp
+	}
+	return DefaultRetryPolicy
+}
+
+// failJob records a failed attempt. Transient errors are retried after a
+// backoff until the policy runs out, at which point the job goes Dead.
+func (p *Pool) failJob(ctx context.Context, job models.Job, err error) {
+	policy := p.retryPolicy(job.JobType)
+
+	status := "Failed"
+	if policy.Retryable(err) {
+		status = "Dead"
+		if job.Attempts < policy.MaxAttempts {
+			status = "Retrying"
+		}
+	}
+
+	var retryIn time.Duration
+	if status == "Retrying" {
+		retryIn = policy.Backoff(job.Attempts)
+		var transient *TransientError
+		if errors.As(err, &transient) {
+			retryIn = max(retryIn, transient.After)
+		}
+	}
+
+	// The queue hands the job out again once retryIn has passed
+	fmt.Printf("[Worker] Job %d %s (attempt %d): %v\n", job.ID, status, job.Attempts, err)
+	if database.RecordJobFailure(ctx, p.DB, job.ID, status, err.Error(), retryIn) == nil {
+		p.publish(job, status)
+	}
+}
@@ -0,0 +1,312 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RobotsRules is the parsed robots.txt group that applies to our user agent.
+type RobotsRules struct {
+	rules      []robotsRule
+	CrawlDelay time.Duration
+	Sitemaps   []string
+	// Unavailable is set when robots.txt answered with a server error. Every
+	// path is disallowed until it can be fetched again
+	Unavailable bool
+}
+
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// Allowed reports whether the URL may be fetched. The longest matching rule
+// wins and Allow beats Disallow on a tie, as Google and RFC 9309 do.
+func (r *RobotsRules) Allowed(u *url.URL) bool {
+	if r == nil {
+		return true
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	allowed, best := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > best || (len(rule.pattern) == best && rule.allow) {
+			allowed, best = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a path against a robots pattern supporting the `*`
+// wildcard and the `$` end anchor.
+func robotsMatch(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "$") {
+		return robotsGlob(strings.TrimSuffix(pattern, "$"), path, true)
+	}
+	return robotsGlob(pattern, path, false)
+}
+
+func robotsGlob(pattern, path string, anchored bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == '*' {
+			pattern = pattern[1:]
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(path); i++ {
+				if robotsGlob(pattern, path[i:], anchored) {
+					return true
+				}
+			}
+			return false
+		}
+		if path == "" || pattern[0] != path[0] {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return !anchored || path == ""
+}
+
+// ParseRobots extracts the rules that apply to userAgent from a robots.txt
+// body. The most specific matching group is used, falling back to `*`.
+func ParseRobots(r io.Reader, userAgent string) *RobotsRules {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	type group struct {
+		agents []string
+		rules  []robotsRule
+		delay  time.Duration
+	}
+	var groups []*group
+	var current *group
+	var sitemaps []string
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || !lastWasAgent {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{pattern: value, allow: key == "allow"})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					current.delay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		}
+		lastWasAgent = false
+	}
+
+	var chosen *group
+	bestLen := -1
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				if bestLen < 0 {
+					chosen, bestLen = g, 0
+				}
+			} else if strings.HasPrefix(token, agent) && len(agent) > bestLen {
+				chosen, bestLen = g, len(agent)
+			}
+		}
+	}
+
+	rules := &RobotsRules{Sitemaps: sitemaps}
+	if chosen != nil {
+		rules.rules = chosen.rules
+		rules.CrawlDelay = chosen.delay
+	}
+	return rules
+}
+
+type robotsEntry struct {
+	ready   chan struct{}
+	expires time.Time
+
+	// body is the robots.txt as fetched; fixed is set instead when the fetch
+	// outcome decides the rules for every user agent (errors, missing file)
+	body  []byte
+	fixed *RobotsRules
+
+	mu     sync.Mutex
+	parsed map[string]*RobotsRules
+}
+
+// rulesFor parses the cached robots.txt for userAgent once and remembers it.
+func (e *robotsEntry) rulesFor(userAgent string) *RobotsRules {
+	if e.fixed != nil {
+		return e.fixed
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if rules, ok := e.parsed[userAgent]; ok {
+		return rules
+	}
+	rules := ParseRobots(bytes.NewReader(e.body), userAgent)
+	e.parsed[userAgent] = rules
+	return rules
+}
+
+// RobotsCache fetches robots.txt once per scheme+host and keeps it for TTL.
+type RobotsCache struct {
+	UserAgent string
+	TTL       time.Duration
+
+	client  *http.Client
+	mu      sync.Mutex
+	entries map[string]*robotsEntry
+	pruned  time.Time // last sweep for expired entries
+}
+
+func NewRobotsCache(userAgent string, ttl time.Duration) *RobotsCache {
+	return &RobotsCache{
+		UserAgent: userAgent,
+		TTL:       ttl,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		entries:   make(map[string]*robotsEntry),
+	}
+}
+
+// Get returns the rules for the URL's host as they apply to the cache's
+// user agent.
+func (c *RobotsCache) Get(u *url.URL) *RobotsRules {
+	return c.GetFor(u, c.UserAgent)
+}
+
+// GetFor returns the rules for the URL's host as they apply to userAgent,
+// fetching robots.txt if the cached copy is missing or stale. Concurrent
+// callers for the same host share a fetch, whatever agent they crawl as.
+func (c *RobotsCache) GetFor(u *url.URL, userAgent string) *RobotsRules {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok {
+		select {
+		case <-entry.ready:
+			if time.Now().After(entry.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		c.prune()
+		entry = &robotsEntry{ready: make(chan struct{}), parsed: make(map[string]*RobotsRules)}
+		c.entries[key] = entry
+		c.mu.Unlock()
+
+		entry.body, entry.fixed = c.fetch(key + "/robots.txt")
+		ttl := c.TTL
+		if entry.fixed != nil && entry.fixed.Unavailable {
+			// Server errors are often brief; don't hold on to one for long
+			ttl = min(ttl, robotsRetryTTL)
+		}
+		entry.expires = time.Now().Add(ttl)
+		close(entry.ready)
+		return entry.rulesFor(userAgent)
+	}
+	c.mu.Unlock()
+
+	<-entry.ready
+	return entry.rulesFor(userAgent)
+}
+
+// prune drops expired entries so hosts the crawl has moved on from don't
+// pile up. It sweeps at most once per TTL and must be called with c.mu held.
+func (c *RobotsCache) prune() {
+	now := time.Now()
+	if now.Sub(c.pruned) < c.TTL {
+		return
+	}
+	c.pruned = now
+
+	for key, entry := range c.entries {
+		select {
+		case <-entry.ready:
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		default: // still being fetched
+		}
+	}
+}
+
+// robotsRetryTTL is how long a robots.txt that answered with a server error
+// is cached before it is fetched again.
+const robotsRetryTTL = time.Minute
+
+// fetch downloads robots.txt. A missing file (4xx) allows everything; a
+// server error marks the rules Unavailable so jobs wait for it to pass,
+// and is only cached for robotsRetryTTL. Network errors
+// allow the crawl so the job fails on its own fetch instead.
+func (c *RobotsCache) fetch(robotsURL string) ([]byte, *RobotsRules) {
+	req, err := http.NewRequest(http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, &RobotsRules{}
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, &RobotsRules{}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return nil, &RobotsRules{rules: []robotsRule{{pattern: "/", allow: false}}, Unavailable: true}
+	case resp.StatusCode >= 400:
+		return nil, &RobotsRules{}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 512<<10))
+	if err != nil {
+		return nil, &RobotsRules{}
+	}
+	return body, nil
+}
@@ -0,0 +1,209 @@
+package worker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRobotsMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/", "/anything", true},
+		{"/private", "/private", true},
+		{"/private", "/private/page", true},
+		{"/private", "/privateer", true},
+		{"/private", "/public", false},
+		{"/private/", "/private", false},
+
+		{"/*.pdf", "/docs/report.pdf", true},
+		{"/*.pdf", "/docs/report.pdf?download=1", true},
+		{"/*.pdf$", "/docs/report.pdf", true},
+		{"/*.pdf$", "/docs/report.pdf?download=1", false},
+		{"/*.pdf$", "/docs/report.pdfx", false},
+		{"/fish*", "/fish", true},
+		{"/fish*", "/fishheads/yummy.html", true},
+		{"/*/edit", "/pages/1/edit", true},
+		{"/*/edit", "/edit", false},
+		{"/a*b*c", "/axxbyyc", true},
+		{"/a*b*c", "/axxcyyb", false},
+
+		{"/$", "/", true},
+		{"/$", "/index.html", false},
+		{"/page$", "/page", true},
+		{"/page$", "/page/", false},
+		{"*", "/anything", true},
+	}
+	for _, tt := range tests {
+		if got := robotsMatch(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("robotsMatch(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsRulesAllowed(t *testing.T) {
+	robots := `
+User-agent: *
+Disallow: /private/
+Allow: /private/public/
+Disallow: /*.pdf$
+Allow: /shared
+Disallow: /shared
+Disallow: /search?
+Allow: /page
+Disallow: /*.php
+`
+	rules := ParseRobots(strings.NewReader(robots), "SentinelBot/1.0")
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/", true},
+		{"https://example.com", true},
+		{"https://example.com/private/", false},
+		{"https://example.com/private/secret.html", false},
+		// The longer Allow wins over the shorter Disallow
+		{"https://example.com/private/public/page.html", true},
+		{"https://example.com/docs/report.pdf", false},
+		{"https://example.com/docs/report.pdf?v=2", true},
+		// Allow beats Disallow on a tie
+		{"https://example.com/shared/file.txt", true},
+		// The query string is part of the matched path
+		{"https://example.com/search", true},
+		{"https://example.com/search?q=go", false},
+		// "/*.php" is longer than "/page", so it wins
+		{"https://example.com/page.php", false},
+		{"https://example.com/page.html", true},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rules.Allowed(u); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsRulesAllowedNil(t *testing.T) {
+	var rules *RobotsRules
+	if !rules.Allowed(&url.URL{Path: "/"}) {
+		t.Error("nil rules should allow everything")
+	}
+}
+
+func TestParseRobotsGroupSelection(t *testing.T) {
+	robots := `
+# Comments and unknown lines are ignored
+Sitemap: https://example.com/sitemap.xml
+
+User-agent: *
+Disallow: /
+Crawl-delay: 1
+
+User-agent: Sentinel
+Disallow: /sentinel-only
+Crawl-delay: 2.5
+
+User-agent: SentinelBot
+User-agent: OtherBot
+Disallow: /bots # trailing comment
+Crawl-delay: 5
+
+User-agent: googlebot
+Disallow: /google-only
+Sitemap: https://example.com/news.xml
+`
+	tests := []struct {
+		agent      string
+		blocked    string
+		allowed    string
+		crawlDelay time.Duration
+	}{
+		// The longest matching agent token wins, compared case-insensitively
+		{"SentinelBot/1.0 (+https://example.com/bot)", "/bots", "/sentinel-only", 5 * time.Second},
+		{"sentinel", "/sentinel-only", "/bots", 2500 * time.Millisecond},
+		// A group listing several agents applies to each of them
+		{"OtherBot", "/bots", "/", 5 * time.Second},
+		{"Googlebot/2.1", "/google-only", "/", 0},
+		// Anything else falls back to the * group
+		{"Mozilla/5.0", "/", "", time.Second},
+	}
+	for _, tt := range tests {
+		rules := ParseRobots(strings.NewReader(robots), tt.agent)
+		if rules.Allowed(&url.URL{Path: tt.blocked}) {
+			t.Errorf("%s: %s should be disallowed", tt.agent, tt.blocked)
+		}
+		if tt.allowed != "" && !rules.Allowed(&url.URL{Path: tt.allowed}) {
+			t.Errorf("%s: %s should be allowed", tt.agent, tt.allowed)
+		}
+		if rules.CrawlDelay != tt.crawlDelay {
+			t.Errorf("%s: CrawlDelay = %v, want %v", tt.agent, rules.CrawlDelay, tt.crawlDelay)
+		}
+		if len(rules.Sitemaps) != 2 {
+			t.Errorf("%s: Sitemaps = %v, want both sitemaps", tt.agent, rules.Sitemaps)
+		}
+	}
+}
+
+func TestParseRobotsNoMatchingGroup(t *testing.T) {
+	robots := `
+User-agent: OtherBot
+Disallow: /
+
+Disallow: /orphan
+`
+	rules := ParseRobots(strings.NewReader(robots), "SentinelBot")
+	for _, path := range []string{"/", "/orphan"} {
+		if !rules.Allowed(&url.URL{Path: path}) {
+			t.Errorf("%s should be allowed when no group matches", path)
+		}
+	}
+}
+
+func TestParseRobotsEmptyDisallow(t *testing.T) {
+	robots := `
+User-agent: *
+Disallow:
+`
+	rules := ParseRobots(strings.NewReader(robots), "SentinelBot")
+	if !rules.Allowed(&url.URL{Path: "/anything"}) {
+		t.Error("an empty Disallow should allow everything")
+	}
+}
+
+func TestRobotsCacheServerError(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	cache := NewRobotsCache("SentinelBot", time.Hour)
+	u, _ := url.Parse(srv.URL + "/page")
+
+	rules := cache.Get(u)
+	if !rules.Unavailable || rules.Allowed(u) {
+		t.Fatalf("a 503 should leave the rules unavailable and disallowing, got %+v", rules)
+	}
+	entry := cache.entries[strings.ToLower(u.Scheme+"://"+u.Host)]
+	if ttl := time.Until(entry.expires); ttl > robotsRetryTTL {
+		t.Errorf("error entry cached for %v, want at most %v", ttl, robotsRetryTTL)
+	}
+
+	// Once the entry expires, a missing robots.txt allows everything
+	status.Store(http.StatusNotFound)
+	entry.expires = time.Now().Add(-time.Second)
+	if rules := cache.Get(u); rules.Unavailable || !rules.Allowed(u) {
+		t.Errorf("a 404 should allow everything, got %+v", rules)
+	}
+}
@@ -0,0 +1,229 @@
+package worker
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"sentinel/internal/models"
+)
+
+// hostQueue holds the jobs waiting for a single host along with the
+// bookkeeping needed to stay polite to it.
+type hostQueue struct {
+	jobs   []models.Job
+	active int
+	lastAt time.Time // when the last job for the host started
+	nextAt time.Time
+	delay  time.Duration
+}
+
+// Scheduler hands jobs to workers while capping the number of concurrent
+// requests per host and spacing out hits to the same host. Hosts are served
+// round-robin so a file dominated by one domain doesn't starve the others.
+type Scheduler struct {
+	MaxPerHost int
+	Delay      time.Duration
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	hosts   map[string]*hostQueue
+	order   []string
+	cursor  int
+	pending int
+	closed  bool
+	wakeAt  time.Time
+
+	// delays holds per-host delay overrides such as a Crawl-delay. They
+	// outlive the host's queue, which is dropped whenever the host goes idle
+	delays map[string]time.Duration
+}
+
+func NewScheduler(maxPerHost int, delay time.Duration) *Scheduler {
+	if maxPerHost < 1 {
+		maxPerHost = 1
+	}
+	s := &Scheduler{
+		MaxPerHost: maxPerHost,
+		Delay:      delay,
+		hosts:      make(map[string]*hostQueue),
+		delays:     make(map[string]time.Duration),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// hostKey returns the lower-cased host a job will hit.
+func hostKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func (s *Scheduler) Push(job models.Job) {
+	host := hostKey(job.URL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.hosts[host]
+	if !ok {
+		q = &hostQueue{delay: s.Delay}
+		if delay, ok := s.delays[host]; ok {
+			q.delay = delay
+		}
+		s.hosts[host] = q
+		s.order = append(s.order, host)
+	}
+	q.jobs = append(q.jobs, job)
+	s.pending++
+	s.cond.Broadcast()
+}
+
+// SetHostDelay raises the delay between hits to a host, e.g. to honor a
+// Crawl-delay. It never lowers the delay below the scheduler default. The
+// next hit is pushed back too, since it was timed with the old delay.
+func (s *Scheduler) SetHostDelay(host string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if delay < s.Delay {
+		delay = s.Delay
+	}
+	if _, ok := s.delays[host]; !ok && len(s.delays) >= 10000 {
+		clear(s.delays)
+	}
+	s.delays[host] = delay
+
+	if q, ok := s.hosts[host]; ok {
+		q.delay = delay
+		if !q.lastAt.IsZero() && q.lastAt.Add(delay).After(q.nextAt) {
+			q.nextAt = q.lastAt.Add(delay)
+		}
+	}
+}
+
+// Next blocks until a job can be started without breaking the per-host limits.
+// It returns false once the scheduler has been drained.
+func (s *Scheduler) Next() (models.Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		now := time.Now()
+		s.dropIdleHosts(now)
+
+		var earliest time.Time
+		for i := 0; i < len(s.order); i++ {
+			idx := (s.cursor + i) % len(s.order)
+			q := s.hosts[s.order[idx]]
+
+			if len(q.jobs) == 0 || q.active >= s.MaxPerHost {
+				continue
+			}
+			if now.Before(q.nextAt) {
+				if earliest.IsZero() || q.nextAt.Before(earliest) {
+					earliest = q.nextAt
+				}
+				continue
+			}
+
+			job := q.jobs[0]
+			q.jobs = q.jobs[1:]
+			q.active++
+			q.lastAt = now
+			q.nextAt = now.Add(q.delay)
+			s.pending--
+			s.cursor = idx + 1
+			return job, true
+		}
+
+		if s.closed && s.pending == 0 {
+			return models.Job{}, false
+		}
+
+		if !earliest.IsZero() && (s.wakeAt.IsZero() || earliest.Before(s.wakeAt)) {
+			s.wakeAt = earliest
+			time.AfterFunc(earliest.Sub(now), func() {
+				s.mu.Lock()
+				s.wakeAt = time.Time{}
+				s.mu.Unlock()
+				s.cond.Broadcast()
+			})
+		}
+		s.cond.Wait()
+	}
+}
+
+// Done releases the host slot taken by a job returned from Next.
+func (s *Scheduler) Done(job models.Job) {
+	host := hostKey(job.URL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q, ok := s.hosts[host]; ok && q.active > 0 {
+		q.active--
+	}
+	s.cond.Broadcast()
+}
+
+// Len returns the number of jobs waiting to be handed to a worker.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pending
+}
+
+// BacklogHosts lists hosts with at least threshold jobs still waiting.
+func (s *Scheduler) BacklogHosts(threshold int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var hosts []string
+	for host, q := range s.hosts {
+		if len(q.jobs) >= threshold {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// Drain closes the scheduler and returns the jobs that never reached a
+// worker. Jobs already handed out are unaffected.
+func (s *Scheduler) Drain() []models.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []models.Job
+	for _, q := range s.hosts {
+		jobs = append(jobs, q.jobs...)
+		q.jobs = nil
+	}
+	s.pending = 0
+	s.closed = true
+	s.cond.Broadcast()
+	return jobs
+}
+
+// dropIdleHosts forgets hosts with nothing queued or in flight once their
+// cool-down has passed, so the round-robin list doesn't grow forever.
+func (s *Scheduler) dropIdleHosts(now time.Time) {
+	kept := s.order[:0]
+	for i, host := range s.order {
+		q := s.hosts[host]
+		if len(q.jobs) == 0 && q.active == 0 && !now.Before(q.nextAt) {
+			delete(s.hosts, host)
+			if i < s.cursor {
+				s.cursor--
+			}
+			continue
+		}
+		kept = append(kept, host)
+	}
+	s.order = kept
+}
@@ -0,0 +1,3 @@
+ALTER TABLE jobs
+ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_error TEXT;
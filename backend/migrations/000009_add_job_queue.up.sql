@@ -0,0 +1,11 @@
+-- Turn the jobs table into a durable queue: workers claim rows with
+-- FOR UPDATE SKIP LOCKED and hold them for a lease (locked_until).
+ALTER TABLE jobs
+ADD COLUMN host TEXT,
+ADD COLUMN run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
+
+UPDATE jobs SET host = lower(substring(url from '^[a-zA-Z][a-zA-Z0-9+.-]*://([^/:?#]+)'));
+
+CREATE INDEX idx_jobs_runnable ON jobs(run_at, id) WHERE status IN ('pending', 'Retrying');
+CREATE INDEX idx_jobs_leased ON jobs(locked_until) WHERE status IN ('Queued', 'InProgress');
@@ -0,0 +1,99 @@
+CREATE TABLE IF NOT EXISTS batches (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    source_file TEXT,
+    settings JSONB NOT NULL DEFAULT '{}',
+    total_jobs INTEGER NOT NULL DEFAULT 0,
+    completed_jobs INTEGER NOT NULL DEFAULT 0,
+    failed_jobs INTEGER NOT NULL DEFAULT 0,
+    blocked_jobs INTEGER NOT NULL DEFAULT 0,
+    dead_jobs INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_batches_user ON batches(user_id);
+CREATE UNIQUE INDEX idx_batches_source_file ON batches(source_file);
+
+ALTER TABLE jobs
+ADD COLUMN batch_id INTEGER REFERENCES batches(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_jobs_batch ON jobs(batch_id, status);
+
+-- Backfill: every uploaded file so far becomes a batch
+INSERT INTO batches (user_id, name, source_file, created_at)
+SELECT MIN(user_id), regexp_replace(file_path, '^.*/[0-9]+_', ''), file_path, MIN(created_at)
+FROM jobs
+WHERE file_path IS NOT NULL
+GROUP BY file_path;
+
+UPDATE jobs j SET batch_id = b.id FROM batches b WHERE b.source_file = j.file_path;
+
+UPDATE batches b SET
+    total_jobs = c.total,
+    completed_jobs = c.completed,
+    failed_jobs = c.failed,
+    blocked_jobs = c.blocked,
+    dead_jobs = c.dead
+FROM (
+    SELECT batch_id,
+        COUNT(*) AS total,
+        COUNT(*) FILTER (WHERE status = 'Completed') AS completed,
+        COUNT(*) FILTER (WHERE status = 'Failed') AS failed,
+        COUNT(*) FILTER (WHERE status = 'Blocked') AS blocked,
+        COUNT(*) FILTER (WHERE status = 'Dead') AS dead
+    FROM jobs
+    WHERE batch_id IS NOT NULL
+    GROUP BY batch_id
+) c
+WHERE b.id = c.batch_id;
+
+-- Keep the aggregate counters in step with the jobs table. Statement-level
+-- triggers with transition tables touch each batch row once per statement,
+-- so a 20k-row bulk insert doesn't turn into 20k counter updates.
+CREATE OR REPLACE FUNCTION batches_count_inserted_jobs() RETURNS trigger AS $$
+BEGIN
+    UPDATE batches b SET total_jobs = b.total_jobs + n.cnt
+    FROM (
+        SELECT batch_id, COUNT(*) AS cnt
+        FROM new_jobs
+        WHERE batch_id IS NOT NULL
+        GROUP BY batch_id
+    ) n
+    WHERE b.id = n.batch_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION batches_count_job_statuses() RETURNS trigger AS $$
+BEGIN
+    UPDATE batches b SET
+        completed_jobs = b.completed_jobs + d.completed,
+        failed_jobs = b.failed_jobs + d.failed,
+        blocked_jobs = b.blocked_jobs + d.blocked,
+        dead_jobs = b.dead_jobs + d.dead
+    FROM (
+        SELECT n.batch_id,
+            SUM((n.status = 'Completed')::int - (o.status = 'Completed')::int) AS completed,
+            SUM((n.status = 'Failed')::int - (o.status = 'Failed')::int) AS failed,
+            SUM((n.status = 'Blocked')::int - (o.status = 'Blocked')::int) AS blocked,
+            SUM((n.status = 'Dead')::int - (o.status = 'Dead')::int) AS dead
+        FROM new_jobs n
+        JOIN old_jobs o ON o.id = n.id
+        WHERE n.batch_id IS NOT NULL AND n.status IS DISTINCT FROM o.status
+        GROUP BY n.batch_id
+    ) d
+    WHERE b.id = d.batch_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER jobs_count_inserted
+AFTER INSERT ON jobs
+REFERENCING NEW TABLE AS new_jobs
+FOR EACH STATEMENT EXECUTE FUNCTION batches_count_inserted_jobs();
+
+CREATE TRIGGER jobs_count_statuses
+AFTER UPDATE ON jobs
+REFERENCING OLD TABLE AS old_jobs NEW TABLE AS new_jobs
+FOR EACH STATEMENT EXECUTE FUNCTION batches_count_job_statuses();
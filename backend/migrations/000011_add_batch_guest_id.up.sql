@@ -0,0 +1,5 @@
+-- Guest batches are owned by an unguessable session ID instead of a user
+ALTER TABLE batches
+ADD COLUMN guest_id TEXT;
+
+CREATE INDEX idx_batches_guest ON batches(guest_id);
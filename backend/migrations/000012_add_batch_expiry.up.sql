@@ -0,0 +1,5 @@
+-- Guest batches expire with the guest session and are purged afterwards
+ALTER TABLE batches
+ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_batches_expires_at ON batches(expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,15 @@
+-- Recursive crawls: child jobs point at the page they were discovered on,
+-- and every URL a crawl has ever enqueued is remembered per batch so it is
+-- fetched once no matter how many pages link to it.
+ALTER TABLE jobs
+ADD COLUMN depth INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN parent_id INTEGER REFERENCES jobs(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_jobs_parent ON jobs(parent_id) WHERE parent_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS crawl_frontier (
+    batch_id INTEGER NOT NULL REFERENCES batches(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (batch_id, url)
+);
@@ -0,0 +1,4 @@
+-- Per-URL data carried in from the source (e.g. sitemap lastmod/priority)
+-- and echoed alongside the job's results.
+ALTER TABLE jobs
+ADD COLUMN metadata JSONB;
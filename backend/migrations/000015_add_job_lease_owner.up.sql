@@ -0,0 +1,6 @@
+-- Leases name their holder, so a worker whose lease ran out and was taken
+-- over by another claimer can tell the job is no longer its to run.
+ALTER TABLE jobs
+ADD COLUMN lease_owner TEXT;
+
+CREATE INDEX idx_jobs_lease_owner ON jobs(lease_owner) WHERE status IN ('Queued', 'InProgress');
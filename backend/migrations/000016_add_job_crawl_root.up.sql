@@ -0,0 +1,5 @@
+-- Crawl scope is anchored to the seed a page descends from, not to where
+-- the page ended up after redirects. Seeds leave it NULL: they are their
+-- own root.
+ALTER TABLE jobs
+ADD COLUMN crawl_root TEXT;
@@ -0,0 +1,7 @@
+-- Uploads are turned into jobs in the background after the batch is
+-- created; the batch records how that went. Existing batches were ingested
+-- before they were created.
+ALTER TABLE batches
+ADD COLUMN ingest_status TEXT NOT NULL DEFAULT 'ready',
+ADD COLUMN ingest_report JSONB,
+ADD COLUMN ingest_error TEXT;
@@ -0,0 +1,28 @@
+-- Only changes into or out of a counted status touch the batch row. Claims
+-- (pending -> Queued) and job starts (Queued -> InProgress) leave every
+-- counter as it was, and rewriting the row for them made every worker
+-- contend for the same lock.
+CREATE OR REPLACE FUNCTION batches_count_job_statuses() RETURNS trigger AS $$
+BEGIN
+    UPDATE batches b SET
+        completed_jobs = b.completed_jobs + d.completed,
+        failed_jobs = b.failed_jobs + d.failed,
+        blocked_jobs = b.blocked_jobs + d.blocked,
+        dead_jobs = b.dead_jobs + d.dead
+    FROM (
+        SELECT n.batch_id,
+            SUM((n.status = 'Completed')::int - (o.status = 'Completed')::int) AS completed,
+            SUM((n.status = 'Failed')::int - (o.status = 'Failed')::int) AS failed,
+            SUM((n.status = 'Blocked')::int - (o.status = 'Blocked')::int) AS blocked,
+            SUM((n.status = 'Dead')::int - (o.status = 'Dead')::int) AS dead
+        FROM new_jobs n
+        JOIN old_jobs o ON o.id = n.id
+        WHERE n.batch_id IS NOT NULL AND n.status IS DISTINCT FROM o.status
+          AND (n.status IN ('Completed', 'Failed', 'Blocked', 'Dead')
+            OR o.status IN ('Completed', 'Failed', 'Blocked', 'Dead'))
+        GROUP BY n.batch_id
+    ) d
+    WHERE b.id = d.batch_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -55,8 +55,9 @@ export const AuthProvider = ({ children }) => {
         await api.post('/api/auth/verify', { email, code });
     }
 
-    const loginAsGuest = () => {
-        localStorage.setItem('token', 'guest-session');
+    const loginAsGuest = async () => {
+        const { data } = await api.post('/api/auth/guest');
+        localStorage.setItem('token', data.token);
         localStorage.setItem('isGuest', 'true');
         setUser({ id: 'guest', isGuest: true });
         setIsGuest(true);
@@ -35,6 +35,13 @@ export default function Dashboard() {
             interval = setInterval(async () => {
                 try {
                     const statusRes = await api.get(`/api/jobs/${currentJob.filename}/status`);
+                    // The upload is parsed in the background and may still turn out unusable
+                    if (statusRes.data.status === 'failed') {
+                        clearInterval(interval);
+                        resetJob();
+                        setError(statusRes.data.error || 'Processing the upload failed');
+                        return;
+                    }
                     setCurrentJob(prev => ({ ...prev, ...statusRes.data }));
 
                     const metricsRes = await api.get(`/api/jobs/${currentJob.filename}/metrics`);
@@ -64,7 +71,7 @@ export default function Dashboard() {
             setElapsedTime(0);
             setCurrentJob({
                 filename: data.filename,
-                total: data.total_found,
+                total: 0,
                 completed: 0,
                 failed: 0,
                 status: 'processing'
@@ -107,7 +114,7 @@ export default function Dashboard() {
         setElapsedTime(0);
     };
 
-    const progress = currentJob ? Math.round(((currentJob.completed + currentJob.failed) / (currentJob.total || 1)) * 100) : 0;
+    const progress = currentJob ? Math.round(((currentJob.completed + currentJob.failed + (currentJob.blocked || 0) + (currentJob.dead || 0)) / (currentJob.total || 1)) * 100) : 0;
     const isComplete = currentJob?.status === 'completed';
 
     return (
@@ -152,7 +159,7 @@ export default function Dashboard() {
                                 type="file"
                                 onChange={(e) => setFile(e.target.files?.[0])}
                                 className="absolute inset-0 w-full h-full opacity-0 cursor-pointer z-10"
-                                accept=".txt,.pdf"
+                                accept=".txt,.md,.csv,.json,.ndjson,.jsonl,.xml,.gz,.zip,.pdf,.docx"
                             />
                             <div className={`border-2 border-dashed rounded-2xl p-12 text-center transition-colors ${file ? 'border-green-500 bg-green-500/5' : 'border-neutral-700 hover:border-neutral-500'}`}>
                                 {file ? (
@@ -215,7 +222,7 @@ export default function Dashboard() {
                         <div className="grid grid-cols-2 md:grid-cols-4 gap-4">
                             <div className="bg-neutral-900 rounded-xl p-5">
                                 <p className="text-sm text-neutral-500 mb-1">Processed</p>
-                                <p className="text-2xl font-semibold">{currentJob.completed + currentJob.failed}/{currentJob.total}</p>
+                                <p className="text-2xl font-semibold">{currentJob.completed + currentJob.failed + (currentJob.blocked || 0) + (currentJob.dead || 0)}/{currentJob.total}</p>
                             </div>
                             <div className="bg-neutral-900 rounded-xl p-5">
                                 <p className="text-sm text-neutral-500 mb-1">Total time</p>
@@ -32,9 +32,13 @@ export default function Login() {
         }
     };
 
-    const handleGuestLogin = () => {
-        loginAsGuest();
-        navigate('/dashboard');
+    const handleGuestLogin = async () => {
+        try {
+            await loginAsGuest();
+            navigate('/dashboard');
+        } catch (err) {
+            setError(err.response?.data?.error || 'Guest login failed');
+        }
     };
 
     const handleGoogleLogin = () => {